@@ -0,0 +1,13 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func assertFloat(t *testing.T, expected float64, actual float64) {
+	t.Helper()
+	if math.Abs(expected-actual) > 1e-9 {
+		t.Fatalf("expected: %f, actual: %f", expected, actual)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import "time"
+
+// Sector of uninvested cash, which has no return and no benchmark weight.
+const cashSector = "Cash"
+
+// attribution explains return of portfolio by holdings captured in snapshots.
+// It is limited to them: positions are assumed to be held unchanged from one
+// snapshot to the next, so trades between rebalances, e.g. stop losses,
+// margin calls or orders filled late, are not accounted for, and returns
+// of the periods do not add up to return of portfolio when there were any.
+type attribution struct {
+	// Sums of weight times return of every period between snapshots.
+	bySymbol map[string]float64
+	bySector map[string]float64
+	// Brinson-Fachler effects of every sector against the universe of
+	// backtested companies, equally weighted. It is not benchmarkSymbol,
+	// whose sector weights are unknown.
+	universeSectorEffects map[string]brinsonEffects
+	// Exposures to criteria of the strategy on every rebalance date.
+	factorExposures []factorExposure
+}
+
+// brinsonEffects split active return of a sector into effect of weighting
+// the sector differently than benchmark and of picking different companies.
+type brinsonEffects struct {
+	allocation  float64
+	selection   float64
+	interaction float64
+}
+
+// factorExposure is the weighted average of criteria results of held
+// companies, standardized across all ranked companies, in criteria order.
+type factorExposure struct {
+	date      time.Time
+	exposures []float64
+}
+
+// attributeSnapshots explains return of every period between snapshots, the
+// last of which ends on to, with held positions and the companies of universe.
+func (p *portfolio) attributeSnapshots(companies []companyInfo, rankings []ranking, to time.Time) attribution {
+	result := attribution{
+		bySymbol:              make(map[string]float64),
+		bySector:              make(map[string]float64),
+		universeSectorEffects: make(map[string]brinsonEffects),
+		factorExposures:       make([]factorExposure, 0),
+	}
+
+	for i, snapshot := range p.snapshots {
+		end := to
+		if i+1 < len(p.snapshots) {
+			end = p.snapshots[i+1].Date
+		}
+		p.attributePeriod(&result, snapshot, companies, end)
+		if exposure, found := factorExposureOf(snapshot, rankings); found {
+			result.factorExposures = append(result.factorExposures, exposure)
+		}
+	}
+
+	return result
+}
+
+type sectorPerformance struct {
+	weight       float64
+	contribution float64
+}
+
+func (s sectorPerformance) sectorReturn() float64 {
+	if s.weight == 0 {
+		return 0
+	}
+	return s.contribution / s.weight
+}
+
+func (p *portfolio) attributePeriod(result *attribution, snapshot snapshot, companies []companyInfo, end time.Time) {
+	portfolioSectors := make(map[string]sectorPerformance)
+	if snapshot.Value != 0 {
+		portfolioSectors[cashSector] = sectorPerformance{weight: snapshot.Cash / snapshot.Value}
+	}
+	for _, position := range snapshot.Positions {
+		company, err := findBySymbol(companies, position.Symbol)
+		if err != nil || position.Shares == 0 {
+			continue
+		}
+		// Priced the same way as the universe, so effects compare like with like
+		startPrice, err := p.basePriceAtOrBefore(company, snapshot.Date)
+		if err != nil || startPrice == 0 {
+			continue
+		}
+		endPrice, err := p.basePriceAtOrBefore(company, end)
+		if err != nil {
+			continue
+		}
+		contribution := position.Weight * (endPrice/startPrice - 1)
+
+		result.bySymbol[position.Symbol] += contribution
+		result.bySector[position.Sector] += contribution
+		sector := portfolioSectors[position.Sector]
+		sector.weight += position.Weight
+		sector.contribution += contribution
+		portfolioSectors[position.Sector] = sector
+	}
+
+	benchmarkSectors, benchmarkReturn := p.universePeriod(companies, snapshot.Date, end)
+	for sector := range portfolioSectors {
+		if _, found := benchmarkSectors[sector]; !found {
+			benchmarkSectors[sector] = sectorPerformance{}
+		}
+	}
+	for sector, benchmark := range benchmarkSectors {
+		held := portfolioSectors[sector]
+		activeWeight := held.weight - benchmark.weight
+		effects := result.universeSectorEffects[sector]
+		effects.allocation += activeWeight * (benchmark.sectorReturn() - benchmarkReturn)
+		effects.selection += benchmark.weight * (held.sectorReturn() - benchmark.sectorReturn())
+		effects.interaction += activeWeight * (held.sectorReturn() - benchmark.sectorReturn())
+		result.universeSectorEffects[sector] = effects
+	}
+}
+
+// universePeriod weights equally every company which can be priced on both
+// start and end of period, returning performance of sectors and total return.
+func (p *portfolio) universePeriod(companies []companyInfo, start time.Time, end time.Time) (map[string]sectorPerformance, float64) {
+	returns := make(map[string]float64)
+	for _, company := range companies {
+		startPrice, err := p.basePriceAtOrBefore(company, start)
+		if err != nil || startPrice == 0 {
+			continue
+		}
+		endPrice, err := p.basePriceAtOrBefore(company, end)
+		if err != nil {
+			continue
+		}
+		returns[company.symbol] = endPrice/startPrice - 1
+	}
+
+	sectors := make(map[string]sectorPerformance)
+	var totalReturn float64
+	for _, company := range companies {
+		companyReturn, priced := returns[company.symbol]
+		if !priced {
+			continue
+		}
+		weight := 1 / float64(len(returns))
+		sector := sectors[company.profile.Sector]
+		sector.weight += weight
+		sector.contribution += weight * companyReturn
+		sectors[company.profile.Sector] = sector
+		totalReturn += weight * companyReturn
+	}
+	return sectors, totalReturn
+}
+
+// basePriceAtOrBefore is close price in base currency on date or,
+// if company did not trade then, on the closest day before it.
+func (p *portfolio) basePriceAtOrBefore(company companyInfo, date time.Time) (float64, error) {
+	price, err := closePriceAtOrBefore(company, date)
+	if err != nil {
+		return 0, err
+	}
+	return p.toBase(company, price, date)
+}
+
+// factorExposureOf standardizes raw criteria results of the ranking
+// of snapshot rebalance date and weights them by positions of snapshot.
+func factorExposureOf(snapshot snapshot, rankings []ranking) (factorExposure, bool) {
+	for _, ranking := range rankings {
+		if !ranking.date.Equal(snapshot.RebalanceDate) {
+			continue
+		}
+		ranked := make([]rankingRow, 0, len(ranking.rows))
+		for _, row := range ranking.rows {
+			if row.droppedReason == "" {
+				ranked = append(ranked, row)
+			}
+		}
+		if len(ranked) == 0 {
+			return factorExposure{}, false
+		}
+
+		exposures := make([]float64, len(ranked[0].rawResults))
+		for j := range exposures {
+			values := make([]float64, len(ranked))
+			for i, row := range ranked {
+				values[i] = row.rawResults[j]
+			}
+			mean, stdDev := Sma(values...), StdDev(values...)
+			if stdDev == 0 {
+				continue
+			}
+			for _, position := range snapshot.Positions {
+				for i, row := range ranked {
+					if row.symbol == position.Symbol {
+						exposures[j] += position.Weight * (values[i] - mean) / stdDev
+					}
+				}
+			}
+		}
+		return factorExposure{date: snapshot.RebalanceDate, exposures: exposures}, true
+	}
+	return factorExposure{}, false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func weeklyInSector(symbol string, sector string, fridayClose float64, mondayClose float64) companyInfo {
+	return companyInfo{
+		symbol:  symbol,
+		profile: Profile{Sector: sector},
+		historicalPrice: HistoricalPrice{
+			Symbol: symbol,
+			Historical: []Price{
+				{Date: "2021-01-18", Close: mondayClose},
+				{Date: "2021-01-15", Close: fridayClose},
+			},
+		},
+	}
+}
+
+var attributionUniverse = []companyInfo{
+	weeklyInSector("TECH1", "Technology", 100, 110),
+	weeklyInSector("TECH2", "Technology", 100, 100),
+	weeklyInSector("UTIL1", "Utilities", 100, 95),
+	weeklyInSector("UTIL2", "Utilities", 100, 105),
+}
+
+func portfolioOfTech1AndUtil1() portfolio {
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	portfolio.performSignalAction(signal{date: friday, company: attributionUniverse[0], price: 100, amountOfShares: 6, action: buy})
+	portfolio.performSignalAction(signal{date: friday, company: attributionUniverse[2], price: 100, amountOfShares: 2, action: buy})
+	portfolio.takeSnapshot(friday, friday)
+	return portfolio
+}
+
+func TestAttribution_contribution_per_symbol_and_sector(t *testing.T) {
+	// Given
+	portfolio := portfolioOfTech1AndUtil1()
+
+	// When
+	attribution := portfolio.attributeSnapshots(attributionUniverse, nil, friday.AddDate(0, 0, 3))
+
+	// Then
+	assertFloat(t, 0.6*0.1, attribution.bySymbol["TECH1"])
+	assertFloat(t, 0.2*-0.05, attribution.bySymbol["UTIL1"])
+	assertFloat(t, 0.06, attribution.bySector["Technology"])
+}
+
+func TestAttribution_brinson_effects_add_up_to_active_return(t *testing.T) {
+	// Given
+	portfolio := portfolioOfTech1AndUtil1()
+
+	// When
+	attribution := portfolio.attributeSnapshots(attributionUniverse, nil, friday.AddDate(0, 0, 3))
+
+	// Then
+	// Portfolio returned 5% against 2.5% of equally weighted universe
+	var activeReturn float64
+	for _, effects := range attribution.universeSectorEffects {
+		activeReturn += effects.allocation + effects.selection + effects.interaction
+	}
+	assertFloat(t, 0.025, activeReturn)
+	// Overweight technology outperformed the universe, underweight utilities did not
+	assertFloat(t, (0.6-0.5)*(0.05-0.025), attribution.universeSectorEffects["Technology"].allocation)
+	assertFloat(t, 0.5*(0.1-0.05), attribution.universeSectorEffects["Technology"].selection)
+}
+
+func TestAttribution_weekend_snapshot_prices_portfolio_and_universe_alike(t *testing.T) {
+	// Given
+	portfolio := portfolioOfTech1AndUtil1()
+	saturday := friday.AddDate(0, 0, 1)
+	portfolio.snapshots = nil
+	portfolio.takeSnapshot(saturday, saturday)
+
+	// When
+	attribution := portfolio.attributeSnapshots(attributionUniverse, nil, friday.AddDate(0, 0, 3))
+
+	// Then
+	// Both start from Friday closes, as if the snapshot was taken on Friday
+	var activeReturn float64
+	for _, effects := range attribution.universeSectorEffects {
+		activeReturn += effects.allocation + effects.selection + effects.interaction
+	}
+	assertFloat(t, 0.025, activeReturn)
+	assertFloat(t, 0.6*0.1, attribution.bySymbol["TECH1"])
+}
+
+func TestAttribution_factor_exposure_of_held_companies(t *testing.T) {
+	// Given
+	portfolio := portfolioOfTech1AndUtil1()
+	rankings := []ranking{{date: friday, rows: []rankingRow{
+		{symbol: "TECH1", rawResults: []float64{0.3}},
+		{symbol: "TECH2", rawResults: []float64{0.2}},
+		{symbol: "UTIL1", rawResults: []float64{0.1}},
+		{symbol: "UTIL2", droppedReason: "missing report"},
+	}}}
+
+	// When
+	attribution := portfolio.attributeSnapshots(attributionUniverse, rankings, friday.AddDate(0, 0, 3))
+
+	// Then
+	// Results standardized to 1, 0 and -1
+	exposures := attribution.factorExposures
+	if len(exposures) != 1 || math.Abs(exposures[0].exposures[0]-(0.6-0.2)) > 1e-9 {
+		t.Fatalf("expected exposure of 0.4, actual exposures: %+v", exposures)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log"
+	"math"
 	"time"
 )
 
@@ -8,34 +10,111 @@ type Backtest struct {
 	screener
 	strategy
 	portfolio
+	// Screens candidates for short positions, the ones screened
+	// for long positions are candidates when not set.
+	shortScreener screener
+	// Symbol of index or ETF prices of which are loaded as portfolio benchmark.
+	benchmarkSymbol string
 }
 
-func (b *Backtest) doBacktest(symbols []string, from time.Time, to time.Time, iterateForDays int) {
-	companies := prepareData(symbols, from, to, b.screener.periodInDays)
+// dataset is everything loaded from data source a backtest runs on. It is
+// only read during backtest, so backtests can share it.
+type dataset struct {
+	companies []companyInfo
+	benchmark companyInfo
+	fxRates   map[string]HistoricalPrice
+}
 
-	currentBacktestDate := from
+func (b *Backtest) doBacktest(symbols []string, from time.Time, to time.Time, iterateForDays int) backtestResult {
+	data := b.loadData(symbols, from, to, b.lookbackPeriod())
+	return b.run(data, from, to, iterateForDays)
+}
 
-	for currentBacktestDate.Before(to) {
-		screenedCompanies := b.screener.screen(companies, currentBacktestDate)
-		topCompanies := b.strategy.evaluateTopCompanies(screenedCompanies, currentBacktestDate, b.portfolio.size)
-		newPositions, err := b.portfolio.calculateNewPositions(topCompanies, currentBacktestDate)
-		if err != nil {
-			currentBacktestDate = currentBacktestDate.AddDate(0, 0, iterateForDays)
-			continue
-		}
-		signals := b.portfolio.generateSignals(newPositions, currentBacktestDate)
-		err = b.portfolio.patchPortfolio(signals)
-		if err != nil {
-			currentBacktestDate = currentBacktestDate.AddDate(0, 0, iterateForDays)
-			continue
+// lookbackPeriod is the number of trading days of data needed before start of backtest.
+func (b *Backtest) lookbackPeriod() int {
+	lookbackPeriod := int(math.Max(float64(b.screener.periodInDays), float64(b.strategy.lookbackInDays())))
+	return int(math.Max(float64(lookbackPeriod), float64(b.portfolio.lookbackInDays())))
+}
+
+func (b *Backtest) loadData(symbols []string, from time.Time, to time.Time, lookbackPeriod int) dataset {
+	data := dataset{companies: prepareData(symbols, from, to, lookbackPeriod)}
+	if b.benchmarkSymbol != "" {
+		data.benchmark = prepareBenchmark(b.benchmarkSymbol, from, to, lookbackPeriod)
+	}
+	if b.portfolio.currency.baseCurrency != "" {
+		data.fxRates = b.portfolio.currency.prepareFxRates(data.companies, from, to, lookbackPeriod)
+	}
+	return data
+}
+
+// run backtests loaded data from from to to, rebalancing every iterateForDays.
+func (b *Backtest) run(data dataset, from time.Time, to time.Time, iterateForDays int) backtestResult {
+	companies := data.companies
+	b.portfolio.benchmark = data.benchmark
+	b.portfolio.currency.fxRates = data.fxRates
+	startValue, err := b.portfolio.calculatePortfolioValue(from)
+	if err != nil {
+		startValue = b.portfolio.capital
+	}
+
+	b.portfolio.scheduledCashFlows = b.portfolio.cashFlows.flows(from, to)
+	nextRebalanceDate := from
+
+	for currentBacktestDate := from; currentBacktestDate.Before(to); currentBacktestDate = currentBacktestDate.AddDate(0, 0, 1) {
+		b.portfolio.applyCashFlows(currentBacktestDate)
+		b.portfolio.fillPendingOrders(currentBacktestDate)
+		b.portfolio.takePendingSnapshot(currentBacktestDate, false)
+		b.portfolio.checkRiskRules(currentBacktestDate)
+		b.portfolio.accrueBorrowFees(currentBacktestDate)
+		b.portfolio.accrueInterest(currentBacktestDate)
+		b.portfolio.checkMargin(currentBacktestDate)
+		b.portfolio.payCapitalGainsTax(currentBacktestDate)
+		if !currentBacktestDate.Before(nextRebalanceDate) {
+			nextRebalanceDate = nextRebalanceDate.AddDate(0, 0, iterateForDays)
+			b.rebalance(companies, currentBacktestDate)
 		}
-		currentBacktestDate = currentBacktestDate.AddDate(0, 0, iterateForDays)
-		//log.Println(b.portfolio.calculatePortfolioValue(to))
+		b.portfolio.recordEquity(currentBacktestDate)
+	}
+	b.portfolio.takePendingSnapshot(to, true)
+
+	finalValue, err := b.portfolio.calculatePortfolioValueAtOrBefore(to)
+	if err != nil {
+		log.Println(err)
 	}
+	result := b.portfolio.summarize(startValue, finalValue, from, to)
+	result.attribution = b.portfolio.attributeSnapshots(companies, b.strategy.rankings, to)
+	return result
+}
 
+func (b *Backtest) rebalance(companies []companyInfo, date time.Time) {
+	b.portfolio.takePendingSnapshot(date, true)
+	b.portfolio.cancelPendingOrders()
+	screenedCompanies := b.screener.screen(companies, date)
+	topCompanies, scores := b.strategy.evaluateTopCompanies(screenedCompanies, date, b.portfolio.size, b.portfolio.holdings(), b.portfolio.sectorLimits)
+	bottomCompanies := make([]companyInfo, 0)
+	if b.portfolio.allowsShorting() {
+		shortCandidates := screenedCompanies
+		if b.shortScreener.screeningStrategy != nil {
+			shortCandidates = b.shortScreener.screen(companies, date)
+		}
+		bottomCompanies = b.strategy.evaluateBottomCompanies(shortCandidates, date, b.portfolio.shortSelling.size, topCompanies)
+	}
+	newPositions, err := b.portfolio.calculateNewPositions(topCompanies, bottomCompanies, scores, date)
+	if err != nil {
+		return
+	}
+	signals := b.portfolio.generateSignals(newPositions, date)
+	b.portfolio.submitOrders(signals)
+	b.portfolio.snapshotRebalance(date)
 }
 
 func prepareData(symbols []string, from time.Time, to time.Time, screeningPeriod int) []companyInfo {
+	return gatherInfo(symbols, extendByLookback(from, screeningPeriod), to)
+}
+
+// extendByLookback moves from back by calendar days covering
+// given number of trading days needed before it.
+func extendByLookback(from time.Time, screeningPeriod int) time.Time {
 	// The NYSE and NASDAQ average about 253 trading days a year.
 	// This is from 365.25 (days on average per year) * 5/7 (proportion work days per week)
 	// - 6 (weekday holidays) - 3*5/7 (fixed Date holidays) = 252.75 ≈ 253.
@@ -43,9 +122,15 @@ func prepareData(symbols []string, from time.Time, to time.Time, screeningPeriod
 	safeOffset := 10.0
 
 	screeningPeriod = int(float64(screeningPeriod)*tradingDaysInYearRatio + safeOffset)
-	from = from.AddDate(0, 0, -screeningPeriod)
+	return from.AddDate(0, 0, -screeningPeriod)
+}
 
-	return gatherInfo(symbols, from, to)
+func prepareBenchmark(symbol string, from time.Time, to time.Time, lookbackPeriod int) companyInfo {
+	histPrice, err := GetHistoricalPrices(symbol, extendByLookback(from, lookbackPeriod), to)
+	if err != nil {
+		panic(err)
+	}
+	return companyInfo{symbol: symbol, historicalPrice: histPrice}
 }
 
 func gatherInfo(symbols []string, from time.Time, to time.Time) []companyInfo {
@@ -56,24 +141,30 @@ func gatherInfo(symbols []string, from time.Time, to time.Time) []companyInfo {
 		if err != nil {
 			panic(err)
 		}
-		//profile, err := GetProfile(tckr)
-		//if err != nil {
-		//	panic(err)
-		//}
-		//finRatios, err := GetFinancialRatios(tckr, from, to)
-		//if err != nil {
-		//	panic(err)
-		//}
+		// Companies without profile, e.g. delisted ones, are of unknown sector
+		profile, err := GetProfile(tckr)
+		if err != nil {
+			log.Println(err, " "+tckr)
+		}
+		finRatios, err := GetFinancialRatios(tckr, from, to)
+		if err != nil {
+			panic(err)
+		}
+		marketCap, err := GetHistoricalMarketCapitalization(tckr, from, to)
+		if err != nil {
+			panic(err)
+		}
 		finGrowth, err := GetFinancialGrowthYearly(tckr, from, to)
 		if err != nil {
 			panic(err)
 		}
 		cmps[i] = companyInfo{
 			tckr,
-			Profile{},
+			profile,
 			histPrice,
-			nil,
+			finRatios,
 			finGrowth,
+			marketCap,
 		}
 	}
 
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// cashFlowSchedule adds external deposits and withdrawals to portfolio cash,
+// e.g. of a savings plan. Deposited cash is invested at the next rebalance,
+// long positions are sold down proportionally when cash does not cover a
+// withdrawal, so it never overdraws cash.
+type cashFlowSchedule struct {
+	// Deposited, or withdrawn when negative, every interval since the start of backtest.
+	amount float64
+	// Months between recurring cash flows, every month when 0.
+	intervalMonths int
+	// Deposits and withdrawals on given dates.
+	oneOff []cashFlow
+}
+
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// appliedCashFlow remembers portfolio value right before the cash flow,
+// which time weighted return is chained with.
+type appliedCashFlow struct {
+	cashFlow
+	valueBefore float64
+}
+
+// flows returns all cash flows after from and before to, ordered by date.
+func (s cashFlowSchedule) flows(from time.Time, to time.Time) []cashFlow {
+	flows := make([]cashFlow, 0)
+	interval := s.intervalMonths
+	if interval == 0 {
+		interval = 1
+	}
+	if s.amount != 0 {
+		// Counted from from every time, so dates do not drift after short months
+		for k := 1; addMonths(from, k*interval).Before(to); k++ {
+			flows = append(flows, cashFlow{addMonths(from, k*interval), s.amount})
+		}
+	}
+	for _, flow := range s.oneOff {
+		if flow.date.After(from) && flow.date.Before(to) {
+			flows = append(flows, flow)
+		}
+	}
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].date.Before(flows[j].date)
+	})
+	return flows
+}
+
+const withdrawalReason = "WITHDRAWAL"
+
+// applyCashFlows adds cash flows due on or before date to cash. Withdrawals
+// cash does not cover wait for a day every held company trades on.
+func (p *portfolio) applyCashFlows(date time.Time) {
+	for len(p.scheduledCashFlows) > 0 && !p.scheduledCashFlows[0].date.After(date) {
+		flow := p.scheduledCashFlows[0]
+		raisesCash := p.capital+flow.amount < 0
+		if raisesCash && !p.positionsTradedOn(date) {
+			return
+		}
+		p.scheduledCashFlows = p.scheduledCashFlows[1:]
+		// Withdrawals waiting for cash to be raised are applied later than scheduled
+		flow.date = date
+
+		valueBefore, err := p.calculatePortfolioValueAtOrBefore(date)
+		if err != nil {
+			log.Println(err)
+			valueBefore = p.capital
+		}
+		if raisesCash {
+			p.raiseCash(-flow.amount, date)
+		}
+		p.capital += flow.amount
+		p.appliedCashFlows = append(p.appliedCashFlows, appliedCashFlow{flow, valueBefore})
+	}
+}
+
+// raiseCash sells long positions proportionally at closes of date until cash
+// covers amount. Fees and slippage of a round leave cash short, which the
+// next one covers. Cash stays short when there is nothing left to sell or
+// a round raised no cash, as further sales would only pay more fees.
+func (p *portfolio) raiseCash(amount float64, date time.Time) {
+	for p.capital < amount {
+		var longValue float64
+		for _, position := range p.positions {
+			if position.amountOfShares <= 0 {
+				continue
+			}
+			positionValue, err := p.positionValue(position, date)
+			if err != nil {
+				continue
+			}
+			longValue += positionValue
+		}
+		if longValue <= 0 {
+			return
+		}
+		fractionToSell := math.Min(1, (amount-p.capital)/longValue)
+
+		sales := make([]signal, 0)
+		for _, position := range p.positions {
+			if position.amountOfShares <= 0 {
+				continue
+			}
+			price, err := closePriceAtOrBefore(position.company, date)
+			if err != nil {
+				continue
+			}
+			sales = append(sales, signal{
+				date:           date,
+				company:        position.company,
+				price:          price,
+				amountOfShares: math.Min(p.shares.roundUp(position.amountOfShares*fractionToSell), position.amountOfShares),
+				action:         sell,
+				reason:         withdrawalReason,
+			})
+		}
+		capitalBefore := p.capital
+		for _, sale := range sales {
+			p.fill(sale)
+		}
+		if p.capital <= capitalBefore {
+			return
+		}
+	}
+}
+
+// timeWeightedReturn chains returns of periods between cash flows,
+// so it measures the strategy regardless of when cash was added or taken.
+func (p *portfolio) timeWeightedReturn(startValue float64, finalValue float64) float64 {
+	growth := 1.0
+	periodStartValue := startValue
+	for _, flow := range p.appliedCashFlows {
+		if periodStartValue != 0 {
+			growth *= flow.valueBefore / periodStartValue
+		}
+		periodStartValue = flow.valueBefore + flow.amount
+	}
+	if periodStartValue == 0 {
+		return growth - 1
+	}
+	return growth*finalValue/periodStartValue - 1
+}
+
+// moneyWeightedReturn is the annual internal rate of return of start value,
+// cash flows and final value, which weights returns by cash invested at the time.
+func (p *portfolio) moneyWeightedReturn(startValue float64, finalValue float64, from time.Time, to time.Time) float64 {
+	flows := []cashFlow{{from, -startValue}}
+	for _, flow := range p.appliedCashFlows {
+		flows = append(flows, cashFlow{flow.date, -flow.amount})
+	}
+	flows = append(flows, cashFlow{to, finalValue})
+	return internalRateOfReturn(flows)
+}
+
+// internalRateOfReturn finds annual rate at which net present value of
+// cash flows is 0 by bisection, NaN when there is no such rate.
+func internalRateOfReturn(flows []cashFlow) float64 {
+	if len(flows) == 0 {
+		return math.NaN()
+	}
+	presentValue := func(rate float64) float64 {
+		var value float64
+		for _, flow := range flows {
+			years := flow.date.Sub(flows[0].date).Hours() / 24 / 365
+			value += flow.amount / math.Pow(1+rate, years)
+		}
+		return value
+	}
+
+	low, high := -0.9999, 100.0
+	if presentValue(low)*presentValue(high) > 0 {
+		return math.NaN()
+	}
+	for i := 0; i < 200 && high-low > 1e-10; i++ {
+		middle := (low + high) / 2
+		if presentValue(low)*presentValue(middle) <= 0 {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return (low + high) / 2
+}
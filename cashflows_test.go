@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var planStart, _ = time.Parse(dateLayout, "2021-01-01")
+
+func TestCashFlows_monthly_deposits_and_one_off_withdrawal(t *testing.T) {
+	// Given
+	schedule := cashFlowSchedule{amount: 1000, oneOff: []cashFlow{{planStart.AddDate(0, 1, 15), -500}}}
+
+	// When
+	flows := schedule.flows(planStart, planStart.AddDate(0, 3, 0))
+
+	// Then
+	expected := []cashFlow{
+		{planStart.AddDate(0, 1, 0), 1000},
+		{planStart.AddDate(0, 1, 15), -500},
+		{planStart.AddDate(0, 2, 0), 1000},
+	}
+	if len(flows) != len(expected) {
+		t.Fatalf("expected cash flows: %+v, actual: %+v", expected, flows)
+	}
+	for i := range expected {
+		if !flows[i].date.Equal(expected[i].date) || flows[i].amount != expected[i].amount {
+			t.Fatalf("expected cash flows: %+v, actual: %+v", expected, flows)
+		}
+	}
+}
+
+func TestCashFlows_deposit_added_to_cash_does_not_change_time_weighted_return(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 100}
+	portfolio.positions = []position{{company: weekly, amountOfShares: 10, atPrice: 100}}
+	portfolio.scheduledCashFlows = []cashFlow{{friday, 900}}
+
+	// When
+	portfolio.applyCashFlows(friday)
+
+	// Then
+	// 1100 before deposit, 2000 after it grew by 50 with the stock to 2050 on Monday
+	assertFloat(t, 1000, portfolio.capital)
+	assertFloat(t, 1100, portfolio.appliedCashFlows[0].valueBefore)
+	assertFloat(t, 50.0/2000, portfolio.timeWeightedReturn(1100, 2050))
+}
+
+func TestCashFlows_internal_rate_of_return(t *testing.T) {
+	// Given
+	flows := []cashFlow{
+		{planStart, -1000},
+		{planStart.AddDate(1, 0, 0), -1000},
+		{planStart.AddDate(2, 0, 0), 2310},
+	}
+
+	// When
+	irr := internalRateOfReturn(flows)
+
+	// Then
+	// 1000 * 1.1^2 + 1000 * 1.1 = 2310, ignoring leap day
+	if math.Abs(irr-0.1) > 1e-3 {
+		t.Fatalf("expected IRR of 10%%, actual: %f", irr)
+	}
+}
+
+func TestCashFlows_monthly_deposits_from_month_end_stay_at_month_end(t *testing.T) {
+	// Given
+	monthEnd, _ := time.Parse(dateLayout, "2021-01-31")
+	schedule := cashFlowSchedule{amount: 1000}
+
+	// When
+	flows := schedule.flows(monthEnd, monthEnd.AddDate(0, 4, 1))
+
+	// Then
+	expected := []string{"2021-02-28", "2021-03-31", "2021-04-30", "2021-05-31"}
+	if len(flows) != len(expected) {
+		t.Fatalf("expected cash flows on: %v, actual: %+v", expected, flows)
+	}
+	for i := range expected {
+		if flows[i].date.Format(dateLayout) != expected[i] {
+			t.Fatalf("expected cash flows on: %v, actual: %+v", expected, flows)
+		}
+	}
+}
+
+func TestCashFlows_withdrawal_sells_positions_on_next_trading_day(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 100}
+	portfolio.positions = []position{{company: weekly, amountOfShares: 10, atPrice: 100}}
+	portfolio.scheduledCashFlows = []cashFlow{{friday.AddDate(0, 0, 1), -500}}
+	monday := friday.AddDate(0, 0, 3)
+
+	// When
+	for date := friday.AddDate(0, 0, 1); !date.After(monday); date = date.AddDate(0, 0, 1) {
+		portfolio.applyCashFlows(date)
+		if date.Before(monday) && portfolio.capital != 100 {
+			t.Fatalf("expected withdrawal to wait for Monday, actual cash on %s: %f", date.Format(dateLayout), portfolio.capital)
+		}
+	}
+
+	// Then
+	// 400 missing raised by selling 4 of 10 shares at Monday close of 105
+	assertFloat(t, 100+4*105-500, portfolio.capital)
+	if portfolio.positions[0].amountOfShares != 6 || len(portfolio.appliedCashFlows) != 1 || !portfolio.appliedCashFlows[0].date.Equal(monday) {
+		t.Fatalf("expected 6 shares left and withdrawal applied on Monday, actual portfolio: %+v", portfolio)
+	}
+}
+
+func TestCashFlows_withdrawal_sells_until_nothing_is_left(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{fixed: 100}, capital: 0}
+	portfolio.positions = []position{{company: weekly, amountOfShares: 10, atPrice: 100}}
+	monday := friday.AddDate(0, 0, 3)
+	portfolio.scheduledCashFlows = []cashFlow{{monday, -500}}
+
+	// When
+	portfolio.applyCashFlows(monday)
+
+	// Then
+	// 5 shares sold first, then a share per round as fees leave cash short,
+	// until all 10 shares at 105 were sold in 6 orders
+	if len(portfolio.positions) != 0 {
+		t.Fatalf("expected every share to be sold, actual positions: %+v", portfolio.positions)
+	}
+	assertFloat(t, 10*105-6*100-500, portfolio.capital)
+	assertFloat(t, 10*105, portfolio.appliedCashFlows[0].valueBefore)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -29,6 +30,8 @@ func GetNasdaqConstituent100() ([]Company, error) {
 	return cmp, nil
 }
 
+var profileNotFound = errors.New("could not find company profile")
+
 func GetProfile(symbol string) (Profile, error) {
 	url := fmt.Sprintf("/profile/%s", symbol)
 	res, err := get(url)
@@ -42,6 +45,9 @@ func GetProfile(symbol string) (Profile, error) {
 	if err != nil {
 		panic(err)
 	}
+	if len(profile) == 0 {
+		return Profile{}, profileNotFound
+	}
 
 	return profile[0], nil
 }
@@ -82,6 +88,25 @@ func GetFinancialRatios(symbol string, from time.Time, to time.Time) ([]Financia
 	return ratios, nil
 }
 
+func GetHistoricalMarketCapitalization(symbol string, from time.Time, to time.Time) ([]MarketCapitalization, error) {
+	strfrom := from.Format(dateLayout)
+	strto := to.Format(dateLayout)
+
+	url := fmt.Sprintf("/historical-market-capitalization/%s?from=%s&to=%s", symbol, strfrom, strto)
+	res, err := get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := make([]MarketCapitalization, 0)
+	err = json.Unmarshal(res, &caps)
+	if err != nil {
+		return nil, err
+	}
+
+	return caps, nil
+}
+
 func GetFinancialGrowthYearly(symbol string, from time.Time, to time.Time) ([]FinancialGrowth, error) {
 	period, limit := convertTimeToYears(from, to)
 	url := fmt.Sprintf("/financial-growth/%s?period=%s&limit=%d", symbol, period, limit)
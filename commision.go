@@ -0,0 +1,194 @@
+package main
+
+import "math"
+
+// commisionModel calculates broker and regulatory fees of a single order.
+type commisionModel interface {
+	calculate(order signal) float64
+}
+
+// volumeRecorder is implemented by commision models whose fees depend
+// on orders filled before, which are recorded once filled.
+type volumeRecorder interface {
+	record(order signal)
+}
+
+func recordVolume(model commisionModel, order signal) {
+	if recorder, ok := model.(volumeRecorder); ok {
+		recorder.record(order)
+	}
+}
+
+// commision is charged as a fixed fee per order plus a fee per share.
+type commision struct {
+	fixed    float64
+	perShare float64
+}
+
+func (c commision) calculate(order signal) float64 {
+	return c.fixed + c.perShare*order.amountOfShares
+}
+
+// percentageCommision is charged as a fraction of order value.
+type percentageCommision struct {
+	rate float64
+}
+
+func (c percentageCommision) calculate(order signal) float64 {
+	return c.rate * orderValue(order)
+}
+
+type commisionTier struct {
+	// Monthly traded shares up to which perShare applies, last tier when 0.
+	upToMonthlyShares float64
+	perShare          float64
+}
+
+// tieredCommision charges per share at a rate decreasing with
+// the amount of shares already traded in the calendar month.
+type tieredCommision struct {
+	tiers         []commisionTier
+	volumeByMonth map[string]float64
+}
+
+func (c *tieredCommision) calculate(order signal) float64 {
+	monthlyVolume := c.volumeByMonth[order.date.Format("2006-01")]
+
+	for _, tier := range c.tiers {
+		if tier.upToMonthlyShares == 0 || monthlyVolume < tier.upToMonthlyShares {
+			return tier.perShare * order.amountOfShares
+		}
+	}
+	return 0
+}
+
+func (c *tieredCommision) record(order signal) {
+	if c.volumeByMonth == nil {
+		c.volumeByMonth = make(map[string]float64)
+	}
+	c.volumeByMonth[order.date.Format("2006-01")] += order.amountOfShares
+}
+
+// regulatoryFees are US fees charged on sells only: SEC fee as a fraction
+// of order value and FINRA trading activity fee per share, capped per order.
+type regulatoryFees struct {
+	secFeeRate  float64
+	tafPerShare float64
+	tafMax      float64
+}
+
+func (f regulatoryFees) calculate(order signal) float64 {
+	if order.action != sell {
+		return 0
+	}
+	taf := f.tafPerShare * order.amountOfShares
+	if f.tafMax > 0 {
+		taf = math.Min(taf, f.tafMax)
+	}
+	return f.secFeeRate*orderValue(order) + taf
+}
+
+// fxCommision is charged as a fraction of order value for
+// converting account currency to the currency of the instrument.
+type fxCommision struct {
+	rate float64
+}
+
+func (c fxCommision) calculate(order signal) float64 {
+	return c.rate * orderValue(order)
+}
+
+// boundedCommision keeps commision of the model between min and max per order.
+// Max may also be given as a fraction of order value, whichever is lower applies.
+type boundedCommision struct {
+	model   commisionModel
+	min     float64
+	max     float64
+	maxRate float64
+}
+
+func (c boundedCommision) calculate(order signal) float64 {
+	fee := math.Max(c.model.calculate(order), c.min)
+	if c.max > 0 {
+		fee = math.Min(fee, c.max)
+	}
+	if c.maxRate > 0 {
+		fee = math.Min(fee, c.maxRate*orderValue(order))
+	}
+	return fee
+}
+
+func (c boundedCommision) record(order signal) {
+	recordVolume(c.model, order)
+}
+
+// combinedCommision is the sum of all its models, e.g. broker
+// commision together with exchange and regulatory fees.
+type combinedCommision []commisionModel
+
+func (c combinedCommision) calculate(order signal) float64 {
+	var fee float64
+	for _, model := range c {
+		fee += model.calculate(order)
+	}
+	return fee
+}
+
+func (c combinedCommision) record(order signal) {
+	for _, model := range c {
+		recordVolume(model, order)
+	}
+}
+
+func orderValue(order signal) float64 {
+	return order.price * order.amountOfShares
+}
+
+// US regulatory fees as of 2024
+var usRegulatoryFees = regulatoryFees{
+	secFeeRate:  0.0000278,
+	tafPerShare: 0.000166,
+	tafMax:      8.30,
+}
+
+// Degiro commision for US stocks
+func degiroUSCommision() commisionModel {
+	return commision{
+		fixed:    0.5,
+		perShare: 0.0034,
+	}
+}
+
+// Interactive Brokers Pro fixed pricing for US stocks, which includes
+// exchange fees but passes through regulatory fees.
+func interactiveBrokersFixedCommision() commisionModel {
+	return combinedCommision{
+		boundedCommision{
+			model:   commision{perShare: 0.005},
+			min:     1,
+			maxRate: 0.01,
+		},
+		usRegulatoryFees,
+	}
+}
+
+// Interactive Brokers Pro tiered pricing for US stocks. Exchange fee
+// is approximated by the common remove liquidity fee, clearing fee is NSCC.
+func interactiveBrokersTieredCommision() commisionModel {
+	return combinedCommision{
+		boundedCommision{
+			model: &tieredCommision{tiers: []commisionTier{
+				{upToMonthlyShares: 300_000, perShare: 0.0035},
+				{upToMonthlyShares: 3_000_000, perShare: 0.002},
+				{upToMonthlyShares: 20_000_000, perShare: 0.0015},
+				{upToMonthlyShares: 100_000_000, perShare: 0.001},
+				{perShare: 0.0005},
+			}},
+			min:     0.35,
+			maxRate: 0.01,
+		},
+		commision{perShare: 0.003},
+		commision{perShare: 0.0002},
+		usRegulatoryFees,
+	}
+}
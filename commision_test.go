@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var orderDate, _ = time.Parse(dateLayout, "2021-01-20")
+
+func TestCommision_fixed_and_per_share(t *testing.T) {
+	// Given
+	order := signal{date: orderDate, price: 100, amountOfShares: 100, action: buy}
+
+	// When
+	fee := degiroUSCommision().calculate(order)
+
+	// Then
+	assertFloat(t, 0.5+0.34, fee)
+}
+
+func TestCommision_percentage_bounded_by_min_and_max(t *testing.T) {
+	// Given
+	model := boundedCommision{model: percentageCommision{rate: 0.001}, min: 2, max: 20}
+	small := signal{date: orderDate, price: 10, amountOfShares: 10, action: buy}
+	medium := signal{date: orderDate, price: 100, amountOfShares: 100, action: buy}
+	large := signal{date: orderDate, price: 1000, amountOfShares: 100, action: buy}
+
+	// Then
+	assertFloat(t, 2, model.calculate(small))
+	assertFloat(t, 10, model.calculate(medium))
+	assertFloat(t, 20, model.calculate(large))
+}
+
+func TestCommision_tiered_by_monthly_volume(t *testing.T) {
+	// Given
+	model := &tieredCommision{tiers: []commisionTier{
+		{upToMonthlyShares: 1000, perShare: 0.01},
+		{perShare: 0.005},
+	}}
+	nextMonth := orderDate.AddDate(0, 1, 0)
+
+	firstOrder := signal{date: orderDate, amountOfShares: 1000, action: buy}
+	secondOrder := signal{date: orderDate, amountOfShares: 1000, action: sell}
+	thirdOrder := signal{date: nextMonth, amountOfShares: 1000, action: buy}
+
+	// When
+	first := model.calculate(firstOrder)
+	recordVolume(model, firstOrder)
+	second := model.calculate(secondOrder)
+	recordVolume(model, secondOrder)
+	third := model.calculate(thirdOrder)
+
+	// Then
+	assertFloat(t, 10, first)
+	assertFloat(t, 5, second)
+	assertFloat(t, 10, third)
+}
+
+func TestCommision_regulatory_fees_on_sells_only(t *testing.T) {
+	// Given
+	buyOrder := signal{date: orderDate, price: 100, amountOfShares: 100_000, action: buy}
+	sellOrder := signal{date: orderDate, price: 100, amountOfShares: 100_000, action: sell}
+
+	// Then
+	assertFloat(t, 0, usRegulatoryFees.calculate(buyOrder))
+	assertFloat(t, 0.0000278*10_000_000+8.30, usRegulatoryFees.calculate(sellOrder))
+}
+
+func TestCommision_interactive_brokers_fixed_minimum(t *testing.T) {
+	// Given
+	order := signal{date: orderDate, price: 300, amountOfShares: 10, action: buy}
+
+	// When
+	fee := interactiveBrokersFixedCommision().calculate(order)
+
+	// Then
+	assertFloat(t, 1, fee)
+}
@@ -1,11 +1,14 @@
 package main
 
+import "backtester/factor"
+
 type companyInfo struct {
 	symbol          string
 	profile         Profile
 	historicalPrice HistoricalPrice
 	ratios          []FinancialRatio
 	growth          []FinancialGrowth
+	marketCap       []MarketCapitalization
 }
 
 type Company struct {
@@ -14,34 +17,36 @@ type Company struct {
 	DateFirstAdded string
 }
 
-type Profile struct {
-	IpoDate     string
-	CompanyName string
-}
-
-type FinancialGrowth struct {
-	Symbol            string
-	Date              string
-	RevenueGrowth     float64
-	GrossProfitGrowth float64
-	NetIncomeGrowth   float64
-}
-
-type FinancialRatio struct {
-	Symbol string
-	Date   string
-	Period string
-}
+// Data of companies is defined in package factor, as criteria defined outside
+// of the backtester are evaluated on it.
+type (
+	Profile              = factor.Profile
+	FinancialGrowth      = factor.FinancialGrowth
+	FinancialRatio       = factor.FinancialRatio
+	MarketCapitalization = factor.MarketCapitalization
+	HistoricalPrice      = factor.HistoricalPrice
+	Price                = factor.Price
+)
 
-type HistoricalPrice struct {
-	Symbol     string
-	Historical []Price
+// view is company as criteria see it.
+func (c companyInfo) view() factor.Company {
+	return factor.Company{
+		Symbol:          c.symbol,
+		Profile:         c.profile,
+		HistoricalPrice: c.historicalPrice,
+		Ratios:          c.ratios,
+		Growth:          c.growth,
+		MarketCap:       c.marketCap,
+	}
 }
 
-type Price struct {
-	Date  string
-	Open  float64
-	Close float64
-	Low   float64
-	High  float64
+func companyInfoOf(company factor.Company) companyInfo {
+	return companyInfo{
+		symbol:          company.Symbol,
+		profile:         company.Profile,
+		historicalPrice: company.HistoricalPrice,
+		ratios:          company.Ratios,
+		growth:          company.Growth,
+		marketCap:       company.MarketCap,
+	}
 }
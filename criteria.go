@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"backtester/factor"
+)
+
+// Criteria. Financial ratios (e.g. priceEarnings) are criteria as well.
+const (
+	revenueGrowth      = "REVENUE_GROWTH"
+	grossProfitGrowth  = "GROSS_PROFIT_GROWTH"
+	netIncomeGrowth    = "NET_INCOME_GROWTH"
+	epsGrowth          = "EPS_GROWTH"
+	freeCashFlowGrowth = "FREE_CASH_FLOW_GROWTH"
+	momentum           = "MOMENTUM"
+	volatility         = "VOLATILITY"
+)
+
+type criterionFunc struct {
+	name     string
+	evaluate func(company companyInfo, period string, date time.Time) (float64, error)
+}
+
+func (c criterionFunc) Name() string {
+	return c.name
+}
+
+func (c criterionFunc) Evaluate(company factor.Company, period string, date time.Time) (float64, error) {
+	return c.evaluate(companyInfoOf(company), period, date)
+}
+
+type priceCriterionFunc struct {
+	criterionFunc
+}
+
+func (c priceCriterionFunc) LookbackInDays(period string) int {
+	days, err := tradingDaysInPeriod(period)
+	if err != nil {
+		return 0
+	}
+	return days + 1
+}
+
+func ratioCriterion(ratio string) criterionFunc {
+	return criterionFunc{ratio, func(company companyInfo, period string, date time.Time) (float64, error) {
+		return getRatioCriterion(company, ratio, period, date)
+	}}
+}
+
+func init() {
+	builtInCriteria := []factor.Criterion{
+		criterionFunc{revenueGrowth, getRevenueGrowth},
+		criterionFunc{grossProfitGrowth, getGrossProfitGrowth},
+		criterionFunc{netIncomeGrowth, getNetIncomeGrowth},
+		criterionFunc{epsGrowth, getEpsGrowth},
+		criterionFunc{freeCashFlowGrowth, getFreeCashFlowGrowth},
+		ratioCriterion(priceEarnings),
+		ratioCriterion(priceToBook),
+		ratioCriterion(returnOnEquity),
+		ratioCriterion(netMargin),
+		priceCriterionFunc{criterionFunc{momentum, getMomentum}},
+		priceCriterionFunc{criterionFunc{volatility, getVolatility}},
+	}
+	for _, criterion := range builtInCriteria {
+		if err := factor.Register(criterion); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func getRevenueGrowth(company companyInfo, period string, date time.Time) (float64, error) {
+	growthReport, err := getGrowthReport(company, period, date)
+	if err != nil {
+		return 0.0, err
+	}
+	return growthReport.RevenueGrowth, nil
+}
+
+func getGrossProfitGrowth(company companyInfo, period string, date time.Time) (float64, error) {
+	growthReport, err := getGrowthReport(company, period, date)
+	if err != nil {
+		return 0.0, err
+	}
+	return growthReport.GrossProfitGrowth, nil
+}
+
+func getNetIncomeGrowth(company companyInfo, period string, date time.Time) (float64, error) {
+	growthReport, err := getGrowthReport(company, period, date)
+	if err != nil {
+		return 0.0, err
+	}
+	return growthReport.NetIncomeGrowth, nil
+}
+
+func getEpsGrowth(company companyInfo, period string, date time.Time) (float64, error) {
+	growthReport, err := getGrowthReport(company, period, date)
+	if err != nil {
+		return 0.0, err
+	}
+	return growthReport.EpsGrowth, nil
+}
+
+func getFreeCashFlowGrowth(company companyInfo, period string, date time.Time) (float64, error) {
+	growthReport, err := getGrowthReport(company, period, date)
+	if err != nil {
+		return 0.0, err
+	}
+	return growthReport.FreeCashFlowGrowth, nil
+}
+
+var ratioPeriodNotSupported = errors.New("period not supported. Supported periods are: PeriodQuarter")
+var earningsNotPositive = errors.New("price earnings ratio of company without positive earnings is not comparable")
+
+// getRatioCriterion returns the ratio in force at given date. P/E of a company
+// without positive earnings is an error, as it would rank it as the cheapest.
+func getRatioCriterion(company companyInfo, ratio string, period string, date time.Time) (float64, error) {
+	if period != PeriodQuarter {
+		return 0.0, ratioPeriodNotSupported
+	}
+	result, err := getRatio(company, ratio, date)
+	if err != nil {
+		return 0.0, err
+	}
+	if ratio == priceEarnings && result <= 0 {
+		return 0.0, earningsNotPositive
+	}
+	return result, nil
+}
+
+// getMomentum returns the price return over given period.
+func getMomentum(company companyInfo, period string, date time.Time) (float64, error) {
+	days, err := tradingDaysInPeriod(period)
+	if err != nil {
+		return 0.0, err
+	}
+	closes, err := getClosePrices(company.historicalPrice.Historical, date, days)
+	if err != nil {
+		return 0.0, err
+	}
+	return closes[0]/closes[days] - 1, nil
+}
+
+// getVolatility returns the standard deviation of daily returns over given period.
+func getVolatility(company companyInfo, period string, date time.Time) (float64, error) {
+	days, err := tradingDaysInPeriod(period)
+	if err != nil {
+		return 0.0, err
+	}
+	closes, err := getClosePrices(company.historicalPrice.Historical, date, days)
+	if err != nil {
+		return 0.0, err
+	}
+	return StdDev(dailyReturns(closes)...), nil
+}
+
+var periodNotSupported = errors.New("period not supported. Supported periods are: periodAnnual")
+var companyGrowthNotFound = errors.New("could not find company growth report for given Date")
+
+func getGrowthReport(company companyInfo, period string, date time.Time) (FinancialGrowth, error) {
+	if period != periodAnnual {
+		return FinancialGrowth{}, periodNotSupported
+	}
+	for _, growthReport := range company.growth {
+		reportDate, err := time.Parse(dateLayout, growthReport.Date)
+		if err != nil {
+			return FinancialGrowth{}, timeParseError
+		}
+		reportDatePlus1Year := reportDate.AddDate(1, 0, 0)
+
+		if reportDate.Before(date) && reportDatePlus1Year.After(date) {
+			return growthReport, nil
+		}
+	}
+
+	return FinancialGrowth{}, companyGrowthNotFound
+}
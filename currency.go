@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// currencyConversion values positions held in instrument currency, taken from
+// company profile, in base currency of portfolio cash. Orders in instrument
+// currency are settled by converting cash at the rate of their date.
+type currencyConversion struct {
+	// Currency of cash and portfolio value, e.g. EUR. Every instrument is
+	// assumed to be in base currency when not set.
+	baseCurrency string
+	// Commision charged on converted amount, value of order and its commision
+	// in base currency, only on orders in currency other than base currency.
+	fee fxCommision
+	// Currency fixed and per share amounts of commision models are given in,
+	// e.g. EUR of the broker account. Commisions are in instrument currency
+	// when not set, as are those of US brokers in USD.
+	commisionCurrency string
+	// Prices of instrument currency in base currency by instrument
+	// currency, e.g. of USDEUR pair by USD when base currency is EUR.
+	fxRates map[string]HistoricalPrice
+}
+
+var fxRateMissing = errors.New("could not find FX rate for given Date")
+
+// fxPair is the symbol of FX rate series converting currency to base currency.
+func (c currencyConversion) fxPair(currency string) string {
+	return currency + c.baseCurrency
+}
+
+// converts tells whether amounts in currency need to be converted.
+func (c currencyConversion) converts(currency string) bool {
+	return c.baseCurrency != "" && currency != "" && currency != c.baseCurrency
+}
+
+// fxRate returns price of a unit of currency in base currency on date or, when
+// it was not quoted then, e.g. on a weekend, the last price before date.
+func (c currencyConversion) fxRate(currency string, date time.Time) (float64, error) {
+	if !c.converts(currency) {
+		return 1, nil
+	}
+	rates, loaded := c.fxRates[currency]
+	if !loaded {
+		return 0, fxRateMissing
+	}
+	rateIndex, err := determinePriceIndexAtOrBefore(rates.Historical, date)
+	if err != nil {
+		return 0, fxRateMissing
+	}
+	return rates.Historical[rateIndex].Close, nil
+}
+
+// toBase converts amount in currency of company to base currency.
+func (p *portfolio) toBase(company companyInfo, amount float64, date time.Time) (float64, error) {
+	rate, err := p.currency.fxRate(company.profile.Currency, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// baseCommision calculates commision of order in commision currency, order
+// value converted to it, and converts the commision to base currency.
+func (p *portfolio) baseCommision(order signal) (float64, error) {
+	currency := p.currency.commisionCurrency
+	if currency == "" {
+		currency = order.company.profile.Currency
+	}
+	orderRate, err := p.currency.fxRate(order.company.profile.Currency, order.date)
+	if err != nil {
+		return 0, err
+	}
+	commisionRate, err := p.currency.fxRate(currency, order.date)
+	if err != nil {
+		return 0, err
+	}
+	order.price *= orderRate / commisionRate
+	return p.commision.calculate(order) * commisionRate, nil
+}
+
+// conversionFee is charged for converting amount in base currency to or from currency.
+func (c currencyConversion) conversionFee(currency string, amount float64) float64 {
+	if !c.converts(currency) {
+		return 0
+	}
+	return math.Abs(amount) * c.fee.rate
+}
+
+// orderConversionFee is charged for converting value of order, and its commision
+// unless paid in commision currency, both in base currency, to or from currency.
+func (c currencyConversion) orderConversionFee(currency string, value float64, commision float64) float64 {
+	if c.commisionCurrency == "" {
+		value += commision
+	}
+	return c.conversionFee(currency, value)
+}
+
+// currencies returns instrument currencies and commision currency other than base currency.
+func (c currencyConversion) currencies(companies []companyInfo) []string {
+	currencies := make([]string, 0)
+	if c.converts(c.commisionCurrency) {
+		currencies = append(currencies, c.commisionCurrency)
+	}
+	for _, company := range companies {
+		currency := company.profile.Currency
+		if c.converts(currency) && !containsString(currencies, currency) {
+			currencies = append(currencies, currency)
+		}
+	}
+	return currencies
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareFxRates loads rates converting every instrument currency
+// of companies to base currency over the backtest period.
+func (c currencyConversion) prepareFxRates(companies []companyInfo, from time.Time, to time.Time, lookbackPeriod int) map[string]HistoricalPrice {
+	fxRates := make(map[string]HistoricalPrice)
+	for _, currency := range c.currencies(companies) {
+		rates, err := GetHistoricalPrices(c.fxPair(currency), extendByLookback(from, lookbackPeriod), to)
+		if err != nil {
+			log.Println(err, " "+c.fxPair(currency))
+			continue
+		}
+		fxRates[currency] = rates
+	}
+	return fxRates
+}
+
+// returnsInCurrencies measures return of portfolio in base currency
+// from from to to in every instrument currency.
+func (c currencyConversion) returnsInCurrencies(totalReturn float64, from time.Time, to time.Time) map[string]float64 {
+	returns := make(map[string]float64)
+	for currency := range c.fxRates {
+		startRate, err := c.fxRate(currency, from)
+		if err != nil {
+			continue
+		}
+		finalRate, err := c.fxRate(currency, to)
+		if err != nil {
+			continue
+		}
+		returns[currency] = (1+totalReturn)*startRate/finalRate - 1
+	}
+	return returns
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+)
+
+var weeklyInUsd = companyInfo{
+	symbol:          weekly.symbol,
+	profile:         Profile{Currency: "USD"},
+	historicalPrice: weekly.historicalPrice,
+}
+
+var usdInEur = currencyConversion{
+	baseCurrency: "EUR",
+	fee:          fxCommision{rate: 0.001},
+	fxRates: map[string]HistoricalPrice{"USD": {
+		Symbol: "USDEUR",
+		Historical: []Price{
+			{Date: "2021-01-18", Close: 0.8},
+			{Date: "2021-01-15", Close: 0.9},
+		},
+	}},
+}
+
+func TestCurrency_order_settles_in_converted_cash_with_fx_fee(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{fixed: 1}, capital: 1000, currency: usdInEur}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weeklyInUsd, price: 100, amountOfShares: 10, action: buy})
+
+	// Then
+	// 10 shares and 1 USD of commision at 0.9 EUR per USD, plus 0.1% FX fee
+	assertFloat(t, 1000-1001*0.9*1.001, portfolio.capital)
+	assertFloat(t, 1001*0.9*0.001, portfolio.paidFxFees)
+	if portfolio.positions[0].lots[0].price != 1001*0.9*1.001/10 {
+		t.Fatalf("expected cost basis in base currency, actual lots: %+v", portfolio.positions[0].lots)
+	}
+}
+
+func TestCurrency_order_in_base_currency_has_no_fx_fee(t *testing.T) {
+	// Given
+	weeklyInEur := companyInfo{symbol: weekly.symbol, profile: Profile{Currency: "EUR"}, historicalPrice: weekly.historicalPrice}
+	portfolio := portfolio{commision: commision{fixed: 1}, capital: 1000, currency: usdInEur}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weeklyInEur, price: 100, amountOfShares: 5, action: buy})
+
+	// Then
+	assertFloat(t, 1000-501, portfolio.capital)
+	assertFloat(t, 0, portfolio.paidFxFees)
+}
+
+func TestCurrency_positions_valued_and_sized_in_base_currency(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 0, currency: usdInEur}
+	portfolio.positions = []position{{company: weeklyInUsd, amountOfShares: 10, atPrice: 100}}
+
+	// When
+	fridayValue, _ := portfolio.calculatePortfolioValue(friday)
+	mondayValue, _ := portfolio.calculatePortfolioValue(friday.AddDate(0, 0, 3))
+	amount, price := portfolio.calculateAmountAndPriceOfShares(weeklyInUsd, 900, friday)
+
+	// Then
+	assertFloat(t, 900, fridayValue)
+	assertFloat(t, 105*10*0.8, mondayValue)
+	if amount != 10 || price != 100 {
+		t.Fatalf("expected 10 shares at 100 USD, actual: %g shares at %g", amount, price)
+	}
+}
+
+func TestCurrency_returns_in_instrument_currency(t *testing.T) {
+	// When
+	returns := usdInEur.returnsInCurrencies(840.0/900-1, friday, friday.AddDate(0, 0, 3))
+
+	// Then
+	// 1000 USD grew to 1050 USD, while the dollar fell against the euro
+	assertFloat(t, 0.05, returns["USD"])
+}
+
+func TestCurrency_weekend_converts_at_last_rate(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 0, currency: usdInEur}
+	portfolio.positions = []position{{company: weeklyInUsd, amountOfShares: 10, atPrice: 100}}
+	saturday := friday.AddDate(0, 0, 1)
+
+	// When
+	saturdayValue, _ := portfolio.calculatePortfolioValue(saturday)
+	returns := usdInEur.returnsInCurrencies(840.0/900-1, saturday, friday.AddDate(0, 0, 3))
+
+	// Then
+	// Friday close of 100 USD at Friday rate of 0.9, not Monday's 105 USD at 0.8
+	assertFloat(t, 900, saturdayValue)
+	assertFloat(t, 0.05, returns["USD"])
+}
+
+func TestCurrency_commision_in_account_currency(t *testing.T) {
+	// Given
+	currency := usdInEur
+	currency.commisionCurrency = "EUR"
+	portfolio := portfolio{commision: boundedCommision{model: percentageCommision{rate: 0.001}, min: 2}, capital: 1100, currency: currency}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weeklyInUsd, price: 100, amountOfShares: 10, action: buy})
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy})
+
+	// Then
+	// Minimum of 2 EUR applies to both 900 EUR and 100 EUR orders, FX fee only to 900 EUR of converted value
+	assertFloat(t, 4, portfolio.paidCommision)
+	assertFloat(t, 900*0.001, portfolio.paidFxFees)
+	assertFloat(t, 1100-900-100-4-0.9, portfolio.capital)
+}
+
+func TestCurrency_polish_tax_lots_at_previous_business_day_rate(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, currency: usdInEur, tax: polishCapitalGainsTax()}
+	monday := friday.AddDate(0, 0, 3)
+
+	// When
+	portfolio.performSignalAction(signal{date: monday, company: weeklyInUsd, price: 105, amountOfShares: 1, action: buy})
+
+	// Then
+	// Cash is converted at Monday rate of 0.8, cost basis at Friday rate of 0.9
+	assertFloat(t, 1000-105*0.8*1.001, portfolio.capital)
+	assertFloat(t, 105*0.9+105*0.8*0.001, portfolio.positions[0].lots[0].price)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Execution modes
+const (
+	// Orders fill at close of the day they were generated on,
+	// which looks ahead as the same close generated them.
+	sameDayClose = "SAME_DAY_CLOSE"
+	nextDayOpen  = "NEXT_DAY_OPEN"
+	nextDayClose = "NEXT_DAY_CLOSE"
+	// Orders fill at typical price of the next day, (High + Low + Close) / 3,
+	// approximating its volume weighted average price.
+	nextDayVwap = "NEXT_DAY_VWAP"
+)
+
+// submitOrders turns signals into orders of types set for buys and sells.
+// Market orders fill right away when executing at the same day close,
+// any other orders stay pending until the next trading day of their company.
+func (p *portfolio) submitOrders(signals []signal) {
+	immediate := make([]signal, 0)
+	signals = p.reducingExposureFirst(signals)
+
+	for _, signal := range signals {
+		if signal.action == hold {
+			continue
+		}
+		if signal.action == buy {
+			signal = p.buyOrder.apply(signal)
+		} else {
+			signal = p.sellOrder.apply(signal)
+		}
+		p.orderStats.placed++
+
+		if signal.isMarketOrder() && (p.execution == "" || p.execution == sameDayClose) {
+			immediate = append(immediate, signal)
+		} else {
+			p.pendingOrders = append(p.pendingOrders, signal)
+		}
+	}
+
+	for _, order := range immediate {
+		p.fillPlaced(order)
+	}
+}
+
+// fillPlaced fills order placed by submitOrders, which counts
+// as filled only once it was settled.
+func (p *portfolio) fillPlaced(order signal) {
+	if p.fill(order) {
+		p.orderStats.filled++
+	}
+}
+
+// fillPendingOrders simulates pending orders against the bar of date, if their
+// company traded on date after the day the order was generated. Market orders
+// fill at price given by execution mode, the others when the bar reaches their
+// limit or stop price. Day orders not filled on their first bar are cancelled.
+func (p *portfolio) fillPendingOrders(date time.Time) {
+	stillPending := make([]signal, 0)
+
+	for _, order := range p.pendingOrders {
+		priceHistory := order.company.historicalPrice.Historical
+		priceIndex, err := determinePriceIndexForDate(priceHistory, date)
+		if err != nil {
+			stillPending = append(stillPending, order)
+			continue
+		}
+		bar := priceHistory[priceIndex]
+		barDate, err := time.Parse(dateLayout, bar.Date)
+		// Bar of a day the order was generated on or of a trading day
+		// yet to come, which is how non-trading days are resolved.
+		if err != nil || !barDate.After(order.date) || barDate.After(date) {
+			stillPending = append(stillPending, order)
+			continue
+		}
+
+		if order.isMarketOrder() {
+			order.date = barDate
+			order.price = p.executionPrice(bar)
+			p.fillPlaced(order)
+			continue
+		}
+
+		order, price, filled := simulateFill(order, bar)
+		switch {
+		case filled:
+			order.date = barDate
+			order.price = price
+			p.fillPlaced(order)
+		case order.timeInForce == goodTillCancelled:
+			stillPending = append(stillPending, order)
+		default:
+			p.orderStats.cancelled++
+		}
+	}
+
+	p.pendingOrders = stillPending
+}
+
+// reducingExposureFirst orders signals so that sells of long positions and
+// covers of short ones go before the orders spending the cash they free.
+func (p *portfolio) reducingExposureFirst(signals []signal) []signal {
+	reducesExposure := func(signal signal) bool {
+		containsSymbol, indexAt := containsSymbol(p.positions, signal.company.symbol)
+		if !containsSymbol {
+			return false
+		}
+		heldShares := p.positions[indexAt].amountOfShares
+		return signal.action == sell && heldShares > 0 || signal.action == buy && heldShares < 0
+	}
+
+	ordered := make([]signal, 0, len(signals))
+	for _, signal := range signals {
+		if reducesExposure(signal) {
+			ordered = append(ordered, signal)
+		}
+	}
+	for _, signal := range signals {
+		if !reducesExposure(signal) {
+			ordered = append(ordered, signal)
+		}
+	}
+	return ordered
+}
+
+func (p *portfolio) executionPrice(bar Price) float64 {
+	switch p.execution {
+	case nextDayOpen:
+		return bar.Open
+	case nextDayVwap:
+		return (bar.High + bar.Low + bar.Close) / 3
+	}
+	return bar.Close
+}
+
+// cancelPendingOrders drops orders not filled until the next rebalance,
+// which generates orders against positions actually held.
+func (p *portfolio) cancelPendingOrders() {
+	for _, order := range p.pendingOrders {
+		log.Printf("cancelling unfilled %s order of %s from %s \n", order.action, order.company.symbol, order.date.Format(dateLayout))
+		p.orderStats.cancelled++
+	}
+	p.pendingOrders = p.pendingOrders[:0]
+}
+
+// cancelPendingOrdersOf drops orders of a company whose position was closed.
+func (p *portfolio) cancelPendingOrdersOf(symbol string) {
+	stillPending := make([]signal, 0)
+	for _, order := range p.pendingOrders {
+		if order.company.symbol == symbol {
+			p.orderStats.cancelled++
+			continue
+		}
+		stillPending = append(stillPending, order)
+	}
+	p.pendingOrders = stillPending
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Friday 2021-01-15 and the following Monday 2021-01-18
+var friday, _ = time.Parse(dateLayout, "2021-01-15")
+
+var weekly = companyInfo{
+	symbol: "WEEK",
+	historicalPrice: HistoricalPrice{
+		Symbol: "WEEK",
+		Historical: []Price{
+			{Date: "2021-01-18", Open: 102, High: 106, Low: 101, Close: 105},
+			{Date: "2021-01-15", Open: 99, High: 101, Low: 98, Close: 100},
+		},
+	},
+}
+
+func TestExecution_next_day_open_fills_on_next_trading_day(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, execution: nextDayOpen}
+	order := signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy}
+
+	// When
+	portfolio.submitOrders([]signal{order})
+	portfolio.fillPendingOrders(friday.AddDate(0, 0, 1))
+	pendingOverWeekend := len(portfolio.pendingOrders)
+	portfolio.fillPendingOrders(friday.AddDate(0, 0, 3))
+
+	// Then
+	if pendingOverWeekend != 1 {
+		t.Fatalf("expected order to stay pending over weekend, actual pending orders: %d", pendingOverWeekend)
+	}
+	if len(portfolio.pendingOrders) != 0 || len(portfolio.positions) != 1 {
+		t.Fatalf("expected order to be filled on monday, actual portfolio: %+v", portfolio)
+	}
+	assertFloat(t, 102, portfolio.positions[0].atPrice)
+	assertFloat(t, 1000-5*102, portfolio.capital)
+}
+
+func TestExecution_next_day_vwap_and_close(t *testing.T) {
+	// Given
+	monday := friday.AddDate(0, 0, 3)
+	vwapPortfolio := portfolio{commision: commision{}, capital: 1000, execution: nextDayVwap}
+	closePortfolio := portfolio{commision: commision{}, capital: 1000, execution: nextDayClose}
+	order := signal{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy}
+
+	// When
+	vwapPortfolio.submitOrders([]signal{order})
+	vwapPortfolio.fillPendingOrders(monday)
+	closePortfolio.submitOrders([]signal{order})
+	closePortfolio.fillPendingOrders(monday)
+
+	// Then
+	assertFloat(t, (106.0+101+105)/3, vwapPortfolio.positions[0].atPrice)
+	assertFloat(t, 105, closePortfolio.positions[0].atPrice)
+}
+
+func TestExecution_same_day_close_fills_immediately(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	order := signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy}
+
+	// When
+	portfolio.submitOrders([]signal{order})
+
+	// Then
+	if len(portfolio.pendingOrders) != 0 || len(portfolio.positions) != 1 {
+		t.Fatalf("expected order to be filled, actual portfolio: %+v", portfolio)
+	}
+	assertFloat(t, 500, portfolio.capital)
+}
@@ -0,0 +1,69 @@
+// Package factor holds the data of a company criteria are evaluated on and the
+// registry of criteria, so criteria can be defined in packages of their own
+// and registered from their init, without changing the backtester.
+package factor
+
+// Company is the data of a company loaded for a backtest, as criteria see it.
+type Company struct {
+	Symbol  string
+	Profile Profile
+	// Daily prices, most recent first.
+	HistoricalPrice HistoricalPrice
+	Ratios          []FinancialRatio
+	Growth          []FinancialGrowth
+	MarketCap       []MarketCapitalization
+}
+
+type Profile struct {
+	IpoDate     string
+	CompanyName string
+	Sector      string
+	Industry    string
+	Country     string
+	MktCap      float64
+	Currency    string
+}
+
+type FinancialGrowth struct {
+	Symbol             string
+	Date               string
+	RevenueGrowth      float64
+	GrossProfitGrowth  float64
+	NetIncomeGrowth    float64
+	EpsGrowth          float64
+	FreeCashFlowGrowth float64
+}
+
+type FinancialRatio struct {
+	Symbol                  string
+	Date                    string
+	Period                  string
+	PriceEarningsRatio      float64
+	PriceToBookRatio        float64
+	PriceToSalesRatio       float64
+	EnterpriseValueMultiple float64
+	ReturnOnEquity          float64
+	NetProfitMargin         float64
+	DebtEquityRatio         float64
+	CurrentRatio            float64
+}
+
+type MarketCapitalization struct {
+	Symbol    string
+	Date      string
+	MarketCap float64
+}
+
+type HistoricalPrice struct {
+	Symbol     string
+	Historical []Price
+}
+
+type Price struct {
+	Date   string
+	Open   float64
+	Close  float64
+	Low    float64
+	High   float64
+	Volume float64
+}
@@ -0,0 +1,48 @@
+package factor
+
+import (
+	"errors"
+	"time"
+)
+
+// Criterion is a single factor companies are ranked by. Strategies look criteria
+// up by criterion type, which has to match the Name they were registered with.
+type Criterion interface {
+	Name() string
+	Evaluate(company Company, period string, date time.Time) (float64, error)
+}
+
+// LookbackCriterion is implemented by criteria evaluated on price history,
+// so enough of it is loaded before the first backtest date.
+type LookbackCriterion interface {
+	LookbackInDays(period string) int
+}
+
+var registry = make(map[string]Criterion)
+
+var CriteriaUnknown = errors.New("unknown criteria for company evaluation were provided")
+var CriterionAlreadyRegistered = errors.New("criterion with given name is already registered")
+
+// Register makes criterion available to strategies under its Name. Criteria
+// are meant to be registered from init of the package defining them, which
+// the backtester then imports for its side effects.
+func Register(criterion Criterion) error {
+	if _, exists := registry[criterion.Name()]; exists {
+		return CriterionAlreadyRegistered
+	}
+	registry[criterion.Name()] = criterion
+	return nil
+}
+
+// Unregister removes criterion registered under name, e.g. one registered by a test.
+func Unregister(name string) {
+	delete(registry, name)
+}
+
+func Lookup(name string) (Criterion, error) {
+	criterion, exists := registry[name]
+	if !exists {
+		return nil, CriteriaUnknown
+	}
+	return criterion, nil
+}
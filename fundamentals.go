@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Financial ratios
+const (
+	priceEarnings  = "PRICE_EARNINGS"
+	priceToBook    = "PRICE_TO_BOOK"
+	priceToSales   = "PRICE_TO_SALES"
+	evToEbitda     = "EV_TO_EBITDA"
+	returnOnEquity = "RETURN_ON_EQUITY"
+	netMargin      = "NET_MARGIN"
+	debtToEquity   = "DEBT_TO_EQUITY"
+	currentRatio   = "CURRENT_RATIO"
+)
+
+var ratioUnknown = errors.New("unknown financial ratio")
+var companyRatioNotFound = errors.New("could not find company ratio report for given Date")
+var companyMarketCapNotFound = errors.New("could not find company market capitalization for given Date")
+
+// getRatioReport returns the quarterly ratio report in force at given date,
+// that is the latest one published not more than a quarter before it.
+func getRatioReport(company companyInfo, date time.Time) (FinancialRatio, error) {
+	for _, ratioReport := range company.ratios {
+		reportDate, err := time.Parse(dateLayout, ratioReport.Date)
+		if err != nil {
+			return FinancialRatio{}, timeParseError
+		}
+		reportDatePlus1Quarter := reportDate.AddDate(0, 3, 0)
+
+		if reportDate.Before(date) && reportDatePlus1Quarter.After(date) {
+			return ratioReport, nil
+		}
+	}
+
+	return FinancialRatio{}, companyRatioNotFound
+}
+
+func getRatio(company companyInfo, ratio string, date time.Time) (float64, error) {
+	report, err := getRatioReport(company, date)
+	if err != nil {
+		return 0.0, err
+	}
+
+	switch ratio {
+	case priceEarnings:
+		return report.PriceEarningsRatio, nil
+	case priceToBook:
+		return report.PriceToBookRatio, nil
+	case priceToSales:
+		return report.PriceToSalesRatio, nil
+	case evToEbitda:
+		return report.EnterpriseValueMultiple, nil
+	case returnOnEquity:
+		return report.ReturnOnEquity, nil
+	case netMargin:
+		return report.NetProfitMargin, nil
+	case debtToEquity:
+		return report.DebtEquityRatio, nil
+	case currentRatio:
+		return report.CurrentRatio, nil
+	}
+	log.Println(ratioUnknown, " "+ratio)
+	return 0.0, ratioUnknown
+}
+
+// getMarketCap returns the market capitalization on given date
+// or on the latest trading day before it.
+func getMarketCap(company companyInfo, date time.Time) (float64, error) {
+	for _, marketCap := range company.marketCap {
+		capDate, err := time.Parse(dateLayout, marketCap.Date)
+		if err != nil {
+			return 0.0, timeParseError
+		}
+		if !capDate.After(date) {
+			return marketCap.MarketCap, nil
+		}
+	}
+
+	return 0.0, companyMarketCapNotFound
+}
@@ -1 +1,3 @@
-module "backtester"
\ No newline at end of file
+module "backtester"
+
+go 1.16
@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"log"
+	"time"
+)
 
 func main() {
 	from, _ := time.Parse(dateLayout, "2017-01-01")
@@ -30,20 +33,19 @@ func main() {
 		criteria: []criterion{revGrowth12crit, profitGrowth12crit},
 	}
 
-	// Degiro commision for US stocks
-	commision := commision{
-		fixed:    0.5,
-		perShare: 0.0034,
-	}
-
 	portfolio := portfolio{
-		commision: commision,
+		commision: degiroUSCommision(),
 		capital:   10000,
 		size:      3,
 		positions: make([]position, 0),
 	}
 
-	backtest := Backtest{screener, strategy, portfolio}
+	backtest := Backtest{
+		screener:  screener,
+		strategy:  strategy,
+		portfolio: portfolio,
+	}
 
-	backtest.doBacktest([]string{"GOOG", "AAL", "INTC", "MSFT", "NVDA", "VRTX"}, from, to, 30)
+	result := backtest.doBacktest([]string{"GOOG", "AAL", "INTC", "MSFT", "NVDA", "VRTX"}, from, to, 30)
+	log.Printf("%+v\n", result)
 }
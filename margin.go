@@ -0,0 +1,190 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+const marginCallReason = "MARGIN_CALL"
+
+// Day counts of annual interest rates, as used by US brokers.
+const (
+	marginInterestDayCount = 360.0
+	cashInterestDayCount   = 365.0
+)
+
+// marginAccount configures borrowing and interest. With no leverage
+// limit the account is a cash account, buys never overdraw the cash.
+type marginAccount struct {
+	// Highest allowed gross exposure, value of long and short positions,
+	// relative to portfolio value.
+	maxLeverage float64
+	// Portfolio value relative to gross exposure below which positions
+	// are sold down to maxLeverage.
+	maintenanceMargin float64
+	// Annual rate charged daily on negative cash.
+	marginInterestRate float64
+	// Annual rates paid daily on idle cash, each in force from its date on.
+	cashInterestRates []ratePoint
+}
+
+type ratePoint struct {
+	date time.Time
+	rate float64
+}
+
+// rateAt returns the rate in force on date, 0 before the first one.
+func rateAt(rates []ratePoint, date time.Time) float64 {
+	rate := 0.0
+	for _, point := range rates {
+		if point.date.After(date) {
+			break
+		}
+		rate = point.rate
+	}
+	return rate
+}
+
+// grossExposure is value of long positions plus absolute value of short ones.
+func (p *portfolio) grossExposure(date time.Time) (float64, error) {
+	var exposure float64
+	for _, position := range p.positions {
+		positionValue, err := p.positionValue(position, date)
+		if err != nil {
+			return 0, err
+		}
+		exposure += math.Abs(positionValue)
+	}
+	return exposure, nil
+}
+
+// affordableShares limits order increasing exposure to the amount of shares
+// the account can pay for: without overdrawing cash in a cash account,
+// without exceeding leverage limit in a margin account.
+func (p *portfolio) affordableShares(order signal) float64 {
+	available := p.capital
+	if p.margin.maxLeverage > 0 {
+		portfolioValue, err := p.calculatePortfolioValue(order.date)
+		if err != nil {
+			return order.amountOfShares
+		}
+		exposure, err := p.grossExposure(order.date)
+		if err != nil {
+			return order.amountOfShares
+		}
+		available = p.margin.maxLeverage*portfolioValue - exposure
+	} else if order.action == sell {
+		// Short sales bring cash in, their size is limited by short selling margin
+		return order.amountOfShares
+	}
+
+	currency := order.company.profile.Currency
+	fxRate, err := p.currency.fxRate(currency, order.date)
+	if err != nil || order.price <= 0 {
+		return order.amountOfShares
+	}
+	basePrice := order.price * fxRate
+	shares := p.shares.roundDown(math.Max(0, math.Min(order.amountOfShares, available/basePrice)))
+	for shares > 0 {
+		order.amountOfShares = shares
+		fee, err := p.baseCommision(order)
+		if err != nil {
+			return order.amountOfShares
+		}
+		fee += p.currency.orderConversionFee(currency, shares*basePrice, fee)
+		if shares*basePrice+fee <= available {
+			break
+		}
+		// Leave room for fees, at least one step less each time
+		shares = p.shares.roundDown(math.Max(0, math.Min(shares-p.shares.step(), (available-fee)/basePrice)))
+	}
+	return shares
+}
+
+// accrueInterest charges one day of margin interest on negative cash
+// and pays one day of interest on cash exceeding short sale proceeds.
+func (p *portfolio) accrueInterest(date time.Time) {
+	if p.capital < 0 {
+		interest := -p.capital * p.margin.marginInterestRate / marginInterestDayCount
+		p.capital -= interest
+		p.paidInterest += interest
+		return
+	}
+
+	rate := rateAt(p.margin.cashInterestRates, date)
+	if rate == 0 {
+		return
+	}
+	idleCash := p.capital
+	for _, position := range p.positions {
+		if position.amountOfShares >= 0 {
+			continue
+		}
+		positionValue, err := p.positionValue(position, date)
+		if err != nil {
+			continue
+		}
+		idleCash += positionValue
+	}
+	if idleCash > 0 {
+		interest := idleCash * rate / cashInterestDayCount
+		p.capital += interest
+		p.earnedInterest += interest
+	}
+}
+
+// checkMargin sells down every position proportionally back to leverage limit
+// when portfolio value fell below maintenance margin of gross exposure. It is
+// checked on days every held company traded, liquidating at their closes.
+func (p *portfolio) checkMargin(date time.Time) {
+	if p.margin.maintenanceMargin == 0 || p.margin.maxLeverage == 0 || !p.positionsTradedOn(date) {
+		return
+	}
+	portfolioValue, err := p.calculatePortfolioValue(date)
+	if err != nil {
+		return
+	}
+	exposure, err := p.grossExposure(date)
+	if err != nil || exposure == 0 || portfolioValue/exposure >= p.margin.maintenanceMargin {
+		return
+	}
+
+	log.Printf("%s: margin call, portfolio value %.2f, gross exposure %.2f \n", date.Format(dateLayout), portfolioValue, exposure)
+	p.marginCalls++
+	fractionToSell := 1 - math.Max(portfolioValue, 0)*p.margin.maxLeverage/exposure
+
+	liquidations := make([]signal, 0)
+	for _, position := range p.positions {
+		price, err := closePriceAtOrBefore(position.company, date)
+		if err != nil {
+			continue
+		}
+		liquidation := signal{
+			date:           date,
+			company:        position.company,
+			price:          price,
+			amountOfShares: math.Min(p.shares.roundUp(math.Abs(position.amountOfShares)*fractionToSell), math.Abs(position.amountOfShares)),
+			action:         sell,
+			reason:         marginCallReason,
+		}
+		if position.amountOfShares < 0 {
+			liquidation.action = buy
+		}
+		liquidations = append(liquidations, liquidation)
+	}
+	for _, liquidation := range liquidations {
+		p.cancelPendingOrdersOf(liquidation.company.symbol)
+		p.fill(liquidation)
+	}
+}
+
+// closePriceAtOrBefore is close price on date or, if company did not trade then,
+// on the closest day before it, so non-trading days never see future prices.
+func closePriceAtOrBefore(company companyInfo, date time.Time) (float64, error) {
+	priceIndex, err := determinePriceIndexAtOrBefore(company.historicalPrice.Historical, date)
+	if err != nil {
+		return 0, err
+	}
+	return company.historicalPrice.Historical[priceIndex].Close, nil
+}
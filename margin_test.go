@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMargin_cash_account_never_overdraws_cash_for_commision(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{fixed: 1}, capital: 1000}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 10, action: buy})
+
+	// Then
+	if portfolio.positions[0].amountOfShares != 9 {
+		t.Fatalf("expected buy limited to 9 shares, actual shares: %g", portfolio.positions[0].amountOfShares)
+	}
+	assertFloat(t, 99, portfolio.capital)
+}
+
+func TestMargin_unaffordable_order_does_not_count_as_filled(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 50}
+
+	// When
+	portfolio.submitOrders([]signal{{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy}})
+
+	// Then
+	expectedStats := orderStats{placed: 1}
+	if len(portfolio.positions) != 0 || portfolio.orderStats != expectedStats {
+		t.Fatalf("expected order stats: %+v, actual portfolio: %+v", expectedStats, portfolio)
+	}
+}
+
+func TestMargin_sells_go_before_buys(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 0}
+	portfolio.positions = []position{{company: apple, amountOfShares: 10, atPrice: 100}}
+	signals := []signal{
+		{date: friday, company: weekly, price: 100, amountOfShares: 10, action: buy},
+		{date: friday, company: apple, price: 100, amountOfShares: 10, action: sell},
+	}
+
+	// When
+	portfolio.submitOrders(signals)
+
+	// Then
+	if len(portfolio.positions) != 1 || portfolio.positions[0].amountOfShares != 10 || portfolio.positions[0].company.symbol != "WEEK" {
+		t.Fatalf("expected apple to be replaced with 10 shares, actual positions: %+v", portfolio.positions)
+	}
+}
+
+func TestMargin_leverage_limit(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, margin: marginAccount{maxLeverage: 1.5}}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 20, action: buy})
+
+	// Then
+	if portfolio.positions[0].amountOfShares != 15 {
+		t.Fatalf("expected buy limited to 15 shares, actual shares: %g", portfolio.positions[0].amountOfShares)
+	}
+	assertFloat(t, -500, portfolio.capital)
+}
+
+func TestMargin_interest_on_negative_and_idle_cash(t *testing.T) {
+	// Given
+	borrowing := portfolio{capital: -3600, margin: marginAccount{marginInterestRate: 0.1}}
+	saving := portfolio{capital: 3650, margin: marginAccount{cashInterestRates: []ratePoint{
+		{date: friday.AddDate(0, -1, 0), rate: 0.01},
+		{date: friday, rate: 0.02},
+		{date: friday.AddDate(0, 0, 1), rate: 0.03},
+	}}}
+
+	// When
+	borrowing.accrueInterest(friday)
+	saving.accrueInterest(friday)
+
+	// Then
+	assertFloat(t, 1, borrowing.paidInterest)
+	assertFloat(t, -3601, borrowing.capital)
+	assertFloat(t, 0.2, saving.earnedInterest)
+}
+
+func TestMargin_call_sells_down_to_leverage_limit(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: -900, margin: marginAccount{maxLeverage: 2, maintenanceMargin: 0.25}}
+	portfolio.positions = []position{{company: weekly, amountOfShares: 10, atPrice: 180}}
+
+	// When
+	portfolio.checkMargin(friday)
+
+	// Then
+	// Portfolio value 10 * 100 - 900 = 100 is 10% of exposure of 1000,
+	// sold down to exposure of 200
+	if portfolio.marginCalls != 1 || portfolio.positions[0].amountOfShares != 2 {
+		t.Fatalf("expected margin call to leave 2 shares, actual portfolio: %+v", portfolio)
+	}
+	assertFloat(t, -100, portfolio.capital)
+	if portfolio.orderStats != (orderStats{}) {
+		t.Fatalf("expected liquidation not to count as order, actual order stats: %+v", portfolio.orderStats)
+	}
+}
+
+func TestMargin_weekend_does_not_see_monday_prices(t *testing.T) {
+	// Given
+	falling := companyInfo{
+		symbol: "FALL",
+		historicalPrice: HistoricalPrice{
+			Symbol: "FALL",
+			Historical: []Price{
+				{Date: "2021-01-18", Close: 60},
+				{Date: "2021-01-15", Close: 100},
+			},
+		},
+	}
+	portfolio := portfolio{commision: commision{}, capital: -500, margin: marginAccount{maxLeverage: 2, maintenanceMargin: 0.25}}
+	portfolio.positions = []position{{company: falling, amountOfShares: 10, atPrice: 100}}
+
+	// When
+	portfolio.checkMargin(friday.AddDate(0, 0, 1))
+	marginCallsOnSaturday := portfolio.marginCalls
+	portfolio.checkMargin(friday.AddDate(0, 0, 3))
+
+	// Then
+	// Portfolio value 10 * 60 - 500 = 100 falls below 25% of exposure of 600 on Monday only
+	if marginCallsOnSaturday != 0 || portfolio.marginCalls != 1 {
+		t.Fatalf("expected margin call on Monday only, actual calls on Saturday: %d, in total: %d", marginCallsOnSaturday, portfolio.marginCalls)
+	}
+}
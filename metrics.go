@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Metrics backtests are compared by
+const (
+	metricTotalReturn  = "TOTAL_RETURN"
+	metricAnnualReturn = "ANNUAL_RETURN"
+	metricVolatility   = "VOLATILITY"
+	metricSharpe       = "SHARPE"
+	metricMaxDrawdown  = "MAX_DRAWDOWN"
+	metricTurnover     = "TURNOVER"
+)
+
+var metricNames = []string{metricTotalReturn, metricAnnualReturn, metricVolatility, metricSharpe, metricMaxDrawdown, metricTurnover}
+
+var metricUnknown = errors.New("unknown metric")
+
+type equityPoint struct {
+	date  time.Time
+	value float64
+}
+
+// metrics measure performance of a backtest from its daily equity curve.
+// Returns exclude deposits and withdrawals.
+type metrics struct {
+	totalReturn float64
+	// Compound annual growth rate.
+	annualReturn float64
+	// Annualized standard deviation of daily returns.
+	volatility float64
+	// Annualized ratio of mean daily return to its standard deviation.
+	sharpe float64
+	// Largest fall from a peak, as a positive fraction of the peak.
+	maxDrawdown float64
+	turnover    float64
+}
+
+func (m metrics) value(metric string) (float64, error) {
+	switch metric {
+	case metricTotalReturn:
+		return m.totalReturn, nil
+	case metricAnnualReturn:
+		return m.annualReturn, nil
+	case metricVolatility:
+		return m.volatility, nil
+	case metricSharpe:
+		return m.sharpe, nil
+	case metricMaxDrawdown:
+		return m.maxDrawdown, nil
+	case metricTurnover:
+		return m.turnover, nil
+	}
+	return 0, metricUnknown
+}
+
+// recordEquity adds portfolio value on date to equity curve, if every
+// held company traded on date, so weekends and holidays are skipped.
+func (p *portfolio) recordEquity(date time.Time) {
+	if len(p.positions) == 0 && (date.Weekday() == time.Saturday || date.Weekday() == time.Sunday) {
+		return
+	}
+	if !p.positionsTradedOn(date) {
+		return
+	}
+	value, err := p.calculatePortfolioValue(date)
+	if err != nil {
+		return
+	}
+	p.equityCurve = append(p.equityCurve, equityPoint{date, value})
+}
+
+// dailyEquityReturns are returns between points of equity curve, less
+// cash flows applied after the first and up to the second point. Return
+// after a point of no value is 0, so returns stay aligned with points.
+func dailyEquityReturns(equityCurve []equityPoint, cashFlows []appliedCashFlow) []float64 {
+	returns := make([]float64, 0, len(equityCurve))
+	for i := 1; i < len(equityCurve); i++ {
+		previous, current := equityCurve[i-1], equityCurve[i]
+		if previous.value == 0 {
+			returns = append(returns, 0)
+			continue
+		}
+		value := current.value
+		for _, flow := range cashFlows {
+			if flow.date.After(previous.date) && !flow.date.After(current.date) {
+				value -= flow.amount
+			}
+		}
+		returns = append(returns, value/previous.value-1)
+	}
+	return returns
+}
+
+func calculateMetrics(equityCurve []equityPoint, cashFlows []appliedCashFlow, turnover float64) metrics {
+	result := metrics{turnover: turnover}
+	returns := dailyEquityReturns(equityCurve, cashFlows)
+	if len(returns) == 0 {
+		return result
+	}
+
+	growth, peak := 1.0, 1.0
+	for _, dailyReturn := range returns {
+		growth *= 1 + dailyReturn
+		peak = math.Max(peak, growth)
+		result.maxDrawdown = math.Max(result.maxDrawdown, 1-growth/peak)
+	}
+	result.totalReturn = growth - 1
+
+	years := equityCurve[len(equityCurve)-1].date.Sub(equityCurve[0].date).Hours() / 24 / 365.25
+	if years > 0 && growth > 0 {
+		result.annualReturn = math.Pow(growth, 1/years) - 1
+	}
+	stdDev := StdDev(returns...)
+	result.volatility = stdDev * math.Sqrt(tradingDaysInYear)
+	if stdDev > 0 {
+		result.sharpe = Sma(returns...) / stdDev * math.Sqrt(tradingDaysInYear)
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+)
+
+// Normalization methods
+const (
+	minMax         = "MIN_MAX"
+	percentileRank = "PERCENTILE_RANK"
+	zScore         = "Z_SCORE"
+	robust         = "ROBUST"
+)
+
+// Scale factor making median absolute deviation a consistent
+// estimator of standard deviation for normally distributed values.
+const madToStdDev = 1.4826
+
+// normalization describes how results of a criterion are made comparable
+// across criteria. Empty method means min-max scaling to [0, 1].
+type normalization struct {
+	method string
+	// Fraction of results clipped at each tail before normalizing (e.g. 0.05
+	// clips to 5th and 95th percentile), so single outliers do not dominate.
+	winsorize float64
+	// Bound of z-scores and robust scores, none when 0.
+	clip float64
+}
+
+var unsupportedDirection = errors.New("unknown direction type")
+var unsupportedNormalization = errors.New("unknown normalization method")
+
+func (n normalization) normalize(values []float64, direction string) ([]float64, error) {
+	if direction != highest && direction != lowest {
+		log.Println(unsupportedDirection, " "+direction)
+		return nil, unsupportedDirection
+	}
+	if n.winsorize > 0 {
+		values = winsorize(values, n.winsorize)
+	}
+
+	var normalized []float64
+	switch n.method {
+	case "", minMax:
+		normalized = normalizeMinMax(values)
+	case percentileRank:
+		normalized = normalizePercentileRank(values)
+	case zScore:
+		normalized = clip(normalizeZScore(values), n.clip)
+	case robust:
+		normalized = clip(normalizeRobust(values), n.clip)
+	default:
+		log.Println(unsupportedNormalization, " "+n.method)
+		return nil, unsupportedNormalization
+	}
+
+	if direction == lowest {
+		for i := range normalized {
+			if n.method == zScore || n.method == robust {
+				normalized[i] = -normalized[i]
+			} else {
+				normalized[i] = 1 - normalized[i]
+			}
+		}
+	}
+
+	return normalized, nil
+}
+
+// normalizeMinMax scales values to [0, 1]. When all values are equal
+// none of them is better, so every one gets the middle of the range.
+func normalizeMinMax(values []float64) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+
+	normalized := make([]float64, len(values))
+	for i, value := range values {
+		if max == min {
+			normalized[i] = 0.5
+			continue
+		}
+		normalized[i] = (value - min) / (max - min)
+	}
+
+	return normalized
+}
+
+// normalizePercentileRank replaces values with their rank scaled to [0, 1].
+// Tied values share the average of their ranks.
+func normalizePercentileRank(values []float64) []float64 {
+	normalized := make([]float64, len(values))
+	if len(values) == 1 {
+		normalized[0] = 0.5
+		return normalized
+	}
+
+	for i, value := range values {
+		lower, equal := 0, 0
+		for _, other := range values {
+			if other < value {
+				lower++
+			} else if other == value {
+				equal++
+			}
+		}
+		normalized[i] = (float64(lower) + float64(equal-1)/2) / float64(len(values)-1)
+	}
+
+	return normalized
+}
+
+func normalizeZScore(values []float64) []float64 {
+	mean := Sma(values...)
+	stdDev := StdDev(values...)
+
+	normalized := make([]float64, len(values))
+	for i, value := range values {
+		if stdDev == 0 {
+			continue
+		}
+		normalized[i] = (value - mean) / stdDev
+	}
+
+	return normalized
+}
+
+// normalizeRobust is a z-score using median and median absolute deviation,
+// which are not moved by a few extreme values.
+func normalizeRobust(values []float64) []float64 {
+	median := quantile(values, 0.5)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - median)
+	}
+	scale := quantile(deviations, 0.5) * madToStdDev
+
+	normalized := make([]float64, len(values))
+	for i, value := range values {
+		if scale == 0 {
+			continue
+		}
+		normalized[i] = (value - median) / scale
+	}
+
+	return normalized
+}
+
+func clip(values []float64, bound float64) []float64 {
+	if bound <= 0 {
+		return values
+	}
+	for i, value := range values {
+		values[i] = math.Max(-bound, math.Min(bound, value))
+	}
+
+	return values
+}
+
+func winsorize(values []float64, fraction float64) []float64 {
+	lowerBound := quantile(values, fraction)
+	upperBound := quantile(values, 1-fraction)
+
+	winsorized := make([]float64, len(values))
+	for i, value := range values {
+		winsorized[i] = math.Max(lowerBound, math.Min(upperBound, value))
+	}
+
+	return winsorized
+}
+
+// quantile returns q-th quantile of values using linear interpolation
+// between closest ranks.
+func quantile(values []float64, q float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	position := q * float64(len(sorted)-1)
+	lowerIndex := int(math.Floor(position))
+	upperIndex := int(math.Ceil(position))
+
+	return sorted[lowerIndex] + (sorted[upperIndex]-sorted[lowerIndex])*(position-float64(lowerIndex))
+}
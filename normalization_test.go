@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+var growthWithOutlier = []float64{0.1, 0.2, 0.3, 9.0}
+
+func assertValues(t *testing.T, expected []float64, actual []float64) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("expected values: %v, actual values: %v", expected, actual)
+	}
+	for i := range expected {
+		if math.Abs(expected[i]-actual[i]) > 1e-6 {
+			t.Fatalf("expected values: %v, actual values: %v", expected, actual)
+		}
+	}
+}
+
+func TestNormalize_min_max_with_equal_values_does_not_divide_by_zero(t *testing.T) {
+	// When
+	normalized, err := normalization{}.normalize([]float64{0.2, 0.2}, highest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{0.5, 0.5}, normalized)
+}
+
+func TestNormalize_percentile_rank_is_not_squashed_by_outlier(t *testing.T) {
+	// When
+	normalized, err := normalization{method: percentileRank}.normalize(growthWithOutlier, highest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{0, 1.0 / 3, 2.0 / 3, 1}, normalized)
+}
+
+func TestNormalize_percentile_rank_averages_ties_and_lowest_direction(t *testing.T) {
+	// When
+	normalized, err := normalization{method: percentileRank}.normalize([]float64{1, 2, 2, 3}, lowest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{1, 0.5, 0.5, 0}, normalized)
+}
+
+func TestNormalize_z_score_with_clipping(t *testing.T) {
+	// When
+	normalized, err := normalization{method: zScore, clip: 1}.normalize(growthWithOutlier, highest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if normalized[3] != 1 {
+		t.Fatalf("expected outlier clipped to 1, actual values: %v", normalized)
+	}
+	for _, value := range normalized[:3] {
+		if value >= 0 {
+			t.Fatalf("expected values below mean to be negative, actual values: %v", normalized)
+		}
+	}
+}
+
+func TestNormalize_robust_is_not_moved_by_outlier(t *testing.T) {
+	// When
+	normalized, err := normalization{method: robust}.normalize(growthWithOutlier, highest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// median 0.25, MAD 0.1
+	assertValues(t, []float64{-0.15 / 0.1 / madToStdDev, -0.05 / 0.1 / madToStdDev, 0.05 / 0.1 / madToStdDev, 8.75 / 0.1 / madToStdDev}, normalized)
+}
+
+func TestNormalize_winsorized_min_max(t *testing.T) {
+	// When
+	normalized, err := normalization{winsorize: 0.25}.normalize([]float64{0, 1, 2, 3, 100}, highest)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{0, 0, 0.5, 1, 1}, normalized)
+}
+
+func TestNormalize_criterion_overrides_strategy_normalization(t *testing.T) {
+	// Given
+	strategy := strategy{
+		criteria: []criterion{
+			{direction: highest},
+			{direction: highest, normalization: normalization{method: percentileRank}},
+		},
+		normalization: normalization{method: minMax},
+	}
+	results := []criteriaEvaluationResult{
+		{companySymbol: "A", results: []float64{0.1, 0.1}},
+		{companySymbol: "B", results: []float64{0.2, 0.2}},
+		{companySymbol: "C", results: []float64{9.0, 9.0}},
+	}
+
+	// When
+	normalized := strategy.normalizeResults(results)
+
+	// Then
+	assertValues(t, []float64{0.1 / 8.9, 0.5}, normalized[1].results)
+	if results[1].results[0] != 0.2 {
+		t.Fatalf("expected raw results to be left intact, actual results: %v", results[1].results)
+	}
+}
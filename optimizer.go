@@ -0,0 +1,183 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	optimizerIterations = 2000
+	// Risk aversions the mean-variance problem is solved for
+	// when searching the efficient frontier for the best Sharpe ratio.
+	minRiskAversion    = 0.1
+	maxRiskAversion    = 1e5
+	riskAversionPoints = 40
+)
+
+// minimumVarianceWeights solves min w'Σw subject to weights summing to 1
+// and 0 <= w <= maxWeight, using projected gradient descent.
+func minimumVarianceWeights(companies []companyInfo, date time.Time, lookbackDays int, maxWeight float64) ([]float64, error) {
+	covariance, err := covarianceMatrix(companies, date, lookbackDays)
+	if err != nil {
+		return nil, err
+	}
+
+	return solveMeanVariance(covariance, make([]float64, len(companies)), 1, maxWeight), nil
+}
+
+// maxSharpeWeights searches the long-only efficient frontier, bounded
+// by maxWeight, for weights of the highest historical Sharpe ratio.
+func maxSharpeWeights(companies []companyInfo, date time.Time, lookbackDays int, maxWeight float64, riskFreeRate float64) ([]float64, error) {
+	covariance, err := covarianceMatrix(companies, date, lookbackDays)
+	if err != nil {
+		return nil, err
+	}
+	meanReturns, err := meanDailyReturns(companies, date, lookbackDays)
+	if err != nil {
+		return nil, err
+	}
+	dailyRiskFreeRate := riskFreeRate / tradingDaysInYear
+
+	var bestWeights []float64
+	bestSharpe := math.Inf(-1)
+	ratio := math.Pow(maxRiskAversion/minRiskAversion, 1/float64(riskAversionPoints-1))
+	for riskAversion := minRiskAversion; riskAversion <= maxRiskAversion*1.0001; riskAversion *= ratio {
+		weights := solveMeanVariance(covariance, meanReturns, riskAversion, maxWeight)
+		sharpe := (dot(meanReturns, weights) - dailyRiskFreeRate) / math.Sqrt(quadraticForm(covariance, weights))
+		if sharpe > bestSharpe {
+			bestSharpe = sharpe
+			bestWeights = weights
+		}
+	}
+
+	if bestWeights == nil {
+		return projectOnBoundedSimplex(make([]float64, len(companies)), weightUpperBound(maxWeight)), nil
+	}
+	return bestWeights, nil
+}
+
+// solveMeanVariance maximizes μ'w - riskAversion/2 * w'Σw over
+// long-only weights bounded by maxWeight and summing to 1.
+func solveMeanVariance(covariance [][]float64, meanReturns []float64, riskAversion float64, maxWeight float64) []float64 {
+	n := len(meanReturns)
+	upperBound := weightUpperBound(maxWeight)
+
+	// Gershgorin bound of the largest eigenvalue gives a step size
+	// for which gradient descent never overshoots.
+	var lipschitz float64
+	for i := range covariance {
+		var rowSum float64
+		for j := range covariance[i] {
+			rowSum += math.Abs(covariance[i][j])
+		}
+		lipschitz = math.Max(lipschitz, riskAversion*rowSum)
+	}
+	if lipschitz == 0 {
+		return projectOnBoundedSimplex(meanReturns, upperBound)
+	}
+	step := 1 / lipschitz
+
+	weights := projectOnBoundedSimplex(make([]float64, n), upperBound)
+	for iteration := 0; iteration < optimizerIterations; iteration++ {
+		moved := make([]float64, n)
+		for i := range weights {
+			var gradient float64
+			for j := range weights {
+				gradient += riskAversion * covariance[i][j] * weights[j]
+			}
+			gradient -= meanReturns[i]
+			moved[i] = weights[i] - step*gradient
+		}
+		weights = projectOnBoundedSimplex(moved, upperBound)
+	}
+
+	return weights
+}
+
+// projectOnBoundedSimplex finds weights closest to values that lie between 0
+// and upperBound and sum to 1, or are all at upperBound if they cannot sum to 1.
+func projectOnBoundedSimplex(values []float64, upperBound float64) []float64 {
+	weights := make([]float64, len(values))
+	target := math.Min(1, upperBound*float64(len(values)))
+
+	shiftedSum := func(shift float64) float64 {
+		var sum float64
+		for i, value := range values {
+			weights[i] = math.Max(0, math.Min(upperBound, value-shift))
+			sum += weights[i]
+		}
+		return sum
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		low = math.Min(low, value-upperBound)
+		high = math.Max(high, value)
+	}
+	for iteration := 0; iteration < 100; iteration++ {
+		middle := (low + high) / 2
+		if shiftedSum(middle) > target {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	shiftedSum((low + high) / 2)
+
+	return weights
+}
+
+func weightUpperBound(maxWeight float64) float64 {
+	if maxWeight > 0 {
+		return maxWeight
+	}
+	return 1
+}
+
+// limitTurnover moves weights from current ones towards target ones only
+// as far as maxTurnover, measured as sum of absolute weight changes, allows.
+func limitTurnover(target []float64, current []float64, maxTurnover float64) []float64 {
+	var turnover float64
+	for i := range target {
+		turnover += math.Abs(target[i] - current[i])
+	}
+	if turnover <= maxTurnover {
+		return target
+	}
+
+	limited := make([]float64, len(target))
+	for i := range target {
+		limited[i] = current[i] + (target[i]-current[i])*maxTurnover/turnover
+	}
+	return limited
+}
+
+func meanDailyReturns(companies []companyInfo, date time.Time, lookbackDays int) ([]float64, error) {
+	meanReturns := make([]float64, len(companies))
+	for i, company := range companies {
+		closes, err := getClosePrices(company.historicalPrice.Historical, date, lookbackDays)
+		if err != nil {
+			return nil, err
+		}
+		meanReturns[i] = Sma(dailyReturns(closes)...)
+	}
+	return meanReturns, nil
+}
+
+func dot(x []float64, y []float64) float64 {
+	var sum float64
+	for i := range x {
+		sum += x[i] * y[i]
+	}
+	return sum
+}
+
+func quadraticForm(matrix [][]float64, x []float64) float64 {
+	var sum float64
+	for i := range x {
+		for j := range x {
+			sum += x[i] * matrix[i][j] * x[j]
+		}
+	}
+	return sum
+}
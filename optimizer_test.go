@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomWalkCloses generates prices with independent daily returns
+// of given standard deviation, most recent first.
+func randomWalkCloses(seed int64, days int, stdDev float64) []float64 {
+	random := rand.New(rand.NewSource(seed))
+	closes := make([]float64, days)
+	closes[days-1] = 100
+	for i := days - 2; i >= 0; i-- {
+		closes[i] = closes[i+1] * (1 + random.NormFloat64()*stdDev)
+	}
+	return closes
+}
+
+func TestProject_on_bounded_simplex(t *testing.T) {
+	// When
+	projected := projectOnBoundedSimplex([]float64{0.9, 0.5, -0.2}, 0.6)
+
+	// Then
+	assertValues(t, []float64{0.6, 0.4, 0}, projected)
+}
+
+func TestProject_on_bounded_simplex_when_bound_too_tight(t *testing.T) {
+	// When
+	projected := projectOnBoundedSimplex([]float64{0.9, 0.5}, 0.3)
+
+	// Then
+	assertValues(t, []float64{0.3, 0.3}, projected)
+}
+
+func TestWeights_minimum_variance_prefers_less_volatile_companies(t *testing.T) {
+	// Given
+	companies := []companyInfo{
+		companyWithCloses("LOW", randomWalkCloses(1, 253, 0.01)),
+		companyWithCloses("MID", randomWalkCloses(2, 253, 0.02)),
+		companyWithCloses("HIGH", randomWalkCloses(3, 253, 0.04)),
+	}
+	covariance, _ := covarianceMatrix(companies, weightingDate, 252)
+
+	// When
+	weights, err := weighting{scheme: minimumVariance, lookbackDays: 252}.calculateWeights(companies, nil, nil, weightingDate)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !(weights[0] > weights[1] && weights[1] > weights[2]) {
+		t.Fatalf("expected weights decreasing with volatility, actual weights: %v", weights)
+	}
+	for i := range weights {
+		perturbed := append([]float64{}, weights...)
+		perturbed[i] += 0.01
+		perturbed[(i+1)%3] -= 0.01
+		if quadraticForm(covariance, perturbed) < quadraticForm(covariance, weights) {
+			t.Fatalf("found weights of lower variance than optimum %v: %v", weights, perturbed)
+		}
+	}
+}
+
+func TestWeights_max_sharpe_respects_max_weight_and_turnover(t *testing.T) {
+	// Given
+	companies := []companyInfo{
+		companyWithCloses("A", randomWalkCloses(4, 253, 0.01)),
+		companyWithCloses("B", randomWalkCloses(5, 253, 0.02)),
+		companyWithCloses("C", randomWalkCloses(6, 253, 0.03)),
+	}
+	currentWeights := map[string]float64{"A": 1.0 / 3, "B": 1.0 / 3, "C": 1.0 / 3}
+	weighting := weighting{scheme: maxSharpe, lookbackDays: 252, maxWeight: 0.5, maxTurnover: 0.1}
+
+	// When
+	weights, err := weighting.calculateWeights(companies, nil, currentWeights, weightingDate)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var turnover, sum float64
+	for _, weight := range weights {
+		if weight > 0.5+1e-9 || weight < 0 {
+			t.Fatalf("expected weights between 0 and 0.5, actual weights: %v", weights)
+		}
+		turnover += math.Abs(weight - 1.0/3)
+		sum += weight
+	}
+	if turnover > 0.1+1e-9 || math.Abs(sum-1) > 1e-6 {
+		t.Fatalf("expected turnover at most 0.1 and weights summing to 1, actual weights: %v", weights)
+	}
+}
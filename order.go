@@ -0,0 +1,105 @@
+package main
+
+import "math"
+
+// Order types
+const (
+	market    = "MARKET"
+	limit     = "LIMIT"
+	stop      = "STOP"
+	stopLimit = "STOP_LIMIT"
+)
+
+// Time in force. Every order still pending at the next rebalance
+// is cancelled, so good till cancelled lasts until then at most.
+const (
+	day               = "DAY"
+	goodTillCancelled = "GTC"
+)
+
+// orderSettings turns signals into orders of given type. Limit and stop
+// prices are set at given fraction away from the price of the signal:
+// limit below it for buys and above it for sells, stop the other way.
+// Limit of a stop-limit order is set away from its stop price instead,
+// above it for buys and below it for sells, as it is active only once
+// the stop price is reached.
+type orderSettings struct {
+	orderType   string
+	limitOffset float64
+	stopOffset  float64
+	timeInForce string
+}
+
+func (s orderSettings) apply(signal signal) signal {
+	if s.orderType == "" {
+		signal.orderType = market
+		return signal
+	}
+	signal.orderType = s.orderType
+	signal.timeInForce = s.timeInForce
+	direction := 1.0
+	if signal.action == sell {
+		direction = -1.0
+	}
+	signal.limitPrice = signal.price * (1 - direction*s.limitOffset)
+	signal.stopPrice = signal.price * (1 + direction*s.stopOffset)
+	if s.orderType == stopLimit {
+		signal.limitPrice = signal.stopPrice * (1 + direction*s.limitOffset)
+	}
+
+	return signal
+}
+
+func (s signal) isMarketOrder() bool {
+	return s.orderType == "" || s.orderType == market
+}
+
+// simulateFill checks whether order fills within the daily bar and at which
+// price, assuming the day opens at Open and then trades through whole range
+// between Low and High. Stop-limit orders triggered by the bar become limit
+// orders, which is returned as the updated order.
+func simulateFill(order signal, bar Price) (signal, float64, bool) {
+	isBuy := order.action == buy
+
+	switch order.orderType {
+	case limit:
+		price, filled := limitFillPrice(isBuy, order.limitPrice, bar.Open, bar)
+		return order, price, filled
+	case stop:
+		if isBuy && bar.High >= order.stopPrice {
+			return order, math.Max(bar.Open, order.stopPrice), true
+		}
+		if !isBuy && bar.Low <= order.stopPrice {
+			return order, math.Min(bar.Open, order.stopPrice), true
+		}
+		return order, 0, false
+	case stopLimit:
+		triggeredAt := bar.Open
+		if !order.triggered {
+			if isBuy && bar.High >= order.stopPrice {
+				triggeredAt = math.Max(bar.Open, order.stopPrice)
+			} else if !isBuy && bar.Low <= order.stopPrice {
+				triggeredAt = math.Min(bar.Open, order.stopPrice)
+			} else {
+				return order, 0, false
+			}
+			order.triggered = true
+		}
+		price, filled := limitFillPrice(isBuy, order.limitPrice, triggeredAt, bar)
+		return order, price, filled
+	}
+
+	return order, bar.Close, true
+}
+
+// limitFillPrice fills limit order at the limit or at a better price
+// the bar trades at from the moment the order becomes active.
+func limitFillPrice(isBuy bool, limitPrice float64, activeFrom float64, bar Price) (float64, bool) {
+	if isBuy && bar.Low <= limitPrice {
+		return math.Min(activeFrom, limitPrice), true
+	}
+	if !isBuy && bar.High >= limitPrice {
+		return math.Max(activeFrom, limitPrice), true
+	}
+	return 0, false
+}
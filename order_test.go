@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+)
+
+var bar = Price{Date: "2021-01-18", Open: 100, High: 104, Low: 97, Close: 102}
+
+func TestOrder_limit_fills_at_limit_or_better_open(t *testing.T) {
+	// Given
+	buyLimit := signal{action: buy, orderType: limit, limitPrice: 98}
+	buyLimitAboveOpen := signal{action: buy, orderType: limit, limitPrice: 101}
+	buyLimitBelowLow := signal{action: buy, orderType: limit, limitPrice: 96}
+	sellLimit := signal{action: sell, orderType: limit, limitPrice: 103}
+
+	// When
+	_, buyPrice, buyFilled := simulateFill(buyLimit, bar)
+	_, aboveOpenPrice, aboveOpenFilled := simulateFill(buyLimitAboveOpen, bar)
+	_, _, belowLowFilled := simulateFill(buyLimitBelowLow, bar)
+	_, sellPrice, sellFilled := simulateFill(sellLimit, bar)
+
+	// Then
+	if !buyFilled || !aboveOpenFilled || belowLowFilled || !sellFilled {
+		t.Fatalf("unexpected fills: %t, %t, %t, %t", buyFilled, aboveOpenFilled, belowLowFilled, sellFilled)
+	}
+	assertFloat(t, 98, buyPrice)
+	assertFloat(t, 100, aboveOpenPrice)
+	assertFloat(t, 103, sellPrice)
+}
+
+func TestOrder_stop_fills_when_reached(t *testing.T) {
+	// Given
+	sellStop := signal{action: sell, orderType: stop, stopPrice: 98}
+	gappedSellStop := signal{action: sell, orderType: stop, stopPrice: 101}
+	buyStop := signal{action: buy, orderType: stop, stopPrice: 105}
+
+	// When
+	_, sellPrice, sellFilled := simulateFill(sellStop, bar)
+	_, gappedPrice, gappedFilled := simulateFill(gappedSellStop, bar)
+	_, _, buyFilled := simulateFill(buyStop, bar)
+
+	// Then
+	if !sellFilled || !gappedFilled || buyFilled {
+		t.Fatalf("unexpected fills: %t, %t, %t", sellFilled, gappedFilled, buyFilled)
+	}
+	assertFloat(t, 98, sellPrice)
+	assertFloat(t, 100, gappedPrice)
+}
+
+func TestOrder_stop_limit_stays_triggered_when_limit_not_reached(t *testing.T) {
+	// Given
+	buyStopLimit := signal{action: buy, orderType: stopLimit, stopPrice: 103, limitPrice: 102}
+	triggeringBar := Price{Date: "2021-01-18", Open: 102.8, High: 104, Low: 102.5, Close: 103.5}
+	nextBar := Price{Date: "2021-01-19", Open: 104, High: 105, Low: 101.5, Close: 104}
+
+	// When
+	triggered, _, filledOnTrigger := simulateFill(buyStopLimit, triggeringBar)
+	_, price, filled := simulateFill(triggered, nextBar)
+
+	// Then
+	if filledOnTrigger || !triggered.triggered {
+		t.Fatalf("expected order to be triggered but not filled, actual order: %+v", triggered)
+	}
+	if !filled {
+		t.Fatalf("expected triggered order to fill as limit order")
+	}
+	assertFloat(t, 102, price)
+}
+
+func TestOrder_day_limit_orders_are_cancelled_and_counted(t *testing.T) {
+	// Given
+	portfolio := portfolio{
+		commision: commision{},
+		capital:   1000,
+		buyOrder:  orderSettings{orderType: limit, limitOffset: 0.01, timeInForce: day},
+	}
+	filledOrder := signal{date: friday, company: weekly, price: 103, amountOfShares: 1, action: buy}
+	unfilledOrder := signal{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy}
+
+	// When
+	portfolio.submitOrders([]signal{filledOrder, unfilledOrder})
+	portfolio.fillPendingOrders(friday.AddDate(0, 0, 3))
+
+	// Then
+	expectedStats := orderStats{placed: 2, filled: 1, cancelled: 1}
+	if portfolio.orderStats != expectedStats || len(portfolio.pendingOrders) != 0 {
+		t.Fatalf("expected order stats: %+v, actual order stats: %+v", expectedStats, portfolio.orderStats)
+	}
+	assertFloat(t, 0.5, portfolio.orderStats.fillRate())
+	assertFloat(t, 101.97, portfolio.positions[0].atPrice)
+}
+
+func TestOrder_buy_stop_limit_fills_once_triggered(t *testing.T) {
+	// Given
+	portfolio := portfolio{
+		commision: commision{},
+		capital:   1000,
+		buyOrder:  orderSettings{orderType: stopLimit, stopOffset: 0.03, limitOffset: 0.01, timeInForce: day},
+	}
+	order := signal{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy}
+
+	// When
+	portfolio.submitOrders([]signal{order})
+	placedOrder := portfolio.pendingOrders[0]
+	portfolio.fillPendingOrders(friday.AddDate(0, 0, 3))
+
+	// Then
+	// Monday trades through stop at 103 and stays below limit 1% above it
+	assertFloat(t, 103, placedOrder.stopPrice)
+	assertFloat(t, 103*1.01, placedOrder.limitPrice)
+	if len(portfolio.positions) != 1 || portfolio.orderStats.filled != 1 {
+		t.Fatalf("expected triggered order to be filled, actual portfolio: %+v", portfolio)
+	}
+	assertFloat(t, 103, portfolio.positions[0].atPrice)
+}
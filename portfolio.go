@@ -2,6 +2,8 @@ package main
 
 import (
 	"errors"
+	"log"
+	"math"
 	"time"
 )
 
@@ -12,73 +14,119 @@ const (
 )
 
 type portfolio struct {
-	commision commision
-	capital   float64
-	size      int
-	positions []position
+	commision     commisionModel
+	capital       float64
+	size          int
+	positions     []position
+	weighting     weighting
+	slippage      slippageModel
+	execution     string
+	buyOrder      orderSettings
+	sellOrder     orderSettings
+	pendingOrders []signal
+	riskRules     riskRules
+	riskStates    map[string]riskState
+	riskExits     []signal
+	shortSelling  shortSelling
+	shares        shareSizing
+	// Prices of benchmark index or ETF, e.g. SPY, beta is measured against.
+	benchmark      companyInfo
+	paidCommision  float64
+	paidSlippage   float64
+	paidBorrowFees float64
+	margin         marginAccount
+	paidInterest   float64
+	earnedInterest float64
+	marginCalls    int
+	orderStats     orderStats
+	tax            taxRules
+	realizedGains  []realizedGain
+	carriedLosses  []carriedLoss
+	// Year realized gains are currently taxed for.
+	taxYear     int
+	paidTax     float64
+	rebalancing rebalancing
+	// Value of filled orders and portfolio value on rebalance dates turnover is measured with.
+	tradedValue        float64
+	rebalanceValues    []float64
+	skippedAdjustments int
+	entryDates         map[string]time.Time
+	exitDates          map[string]time.Time
+	sectorLimits       sectorLimits
+	currency           currencyConversion
+	paidFxFees         float64
+	cashFlows          cashFlowSchedule
+	scheduledCashFlows []cashFlow
+	appliedCashFlows   []appliedCashFlow
+	snapshots          []snapshot
+	// Rebalance date whose snapshot waits for its orders to fill.
+	snapshotPendingSince time.Time
+	equityCurve          []equityPoint
+}
+
+type orderStats struct {
+	placed    int
+	filled    int
+	cancelled int
 }
 
 type position struct {
 	company        companyInfo
-	amountOfShares int
+	amountOfShares float64
 	atPrice        float64
-}
-
-type commision struct {
-	fixed    float64
-	perShare float64
+	lots           []taxLot
 }
 
 type signal struct {
 	date           time.Time
 	company        companyInfo
 	price          float64
-	amountOfShares int
+	amountOfShares float64
 	action         string
+	orderType      string
+	limitPrice     float64
+	stopPrice      float64
+	timeInForce    string
+	// Whether stop price of stop-limit order has been reached.
+	triggered bool
+	// Why the order was generated outside of rebalance, e.g. stopLossReason.
+	reason string
 }
 
+// generateSignals compares new positions with held ones. Shares of short
+// positions are negative, so buying covers them and selling opens them.
+// Adjustments of held positions not worth trading by rebalancing rules are held.
 func (p *portfolio) generateSignals(newPositions []position, date time.Time) []signal {
 	signals := make([]signal, 0)
+	portfolioValue := p.recordRebalance(date)
 
 	for _, position := range newPositions {
 		contains, atIndex := contains(p.positions, position)
-		var sig signal
 		newSharesAmount := position.amountOfShares
+		sharesCurrentlyHeldAmount := 0.0
 		if contains {
-			sharesCurrentlyHeldAmount := p.positions[atIndex].amountOfShares
-			if sharesCurrentlyHeldAmount > newSharesAmount {
-				sig = signal{
-					date:           date,
-					company:        position.company,
-					price:          position.atPrice,
-					amountOfShares: sharesCurrentlyHeldAmount - newSharesAmount,
-					action:         sell,
-				}
-			} else if sharesCurrentlyHeldAmount == newSharesAmount {
-				sig = signal{
-					date:           date,
-					company:        position.company,
-					price:          position.atPrice,
-					amountOfShares: sharesCurrentlyHeldAmount,
-					action:         hold,
-				}
-			} else if sharesCurrentlyHeldAmount < newSharesAmount {
-				sig = signal{
-					date:           date,
-					company:        position.company,
-					price:          position.atPrice,
-					amountOfShares: newSharesAmount - sharesCurrentlyHeldAmount,
-					action:         buy,
-				}
-			}
+			sharesCurrentlyHeldAmount = p.positions[atIndex].amountOfShares
+		}
+		sig := signal{
+			date:    date,
+			company: position.company,
+			price:   position.atPrice,
+		}
+		basePrice, err := p.toBase(position.company, position.atPrice, date)
+		if err == nil && sharesCurrentlyHeldAmount != newSharesAmount && isAdjustment(sharesCurrentlyHeldAmount, newSharesAmount) &&
+			p.rebalancing.holds(sharesCurrentlyHeldAmount, newSharesAmount, basePrice, portfolioValue) {
+			p.skippedAdjustments++
+			newSharesAmount = sharesCurrentlyHeldAmount
+		}
+		if sharesCurrentlyHeldAmount > newSharesAmount {
+			sig.amountOfShares = p.shares.round(sharesCurrentlyHeldAmount - newSharesAmount)
+			sig.action = sell
+		} else if sharesCurrentlyHeldAmount == newSharesAmount {
+			sig.amountOfShares = sharesCurrentlyHeldAmount
+			sig.action = hold
 		} else {
-			sig = signal{
-				date:           date,
-				company:        position.company,
-				price:          position.atPrice,
-				amountOfShares: newSharesAmount,
-				action:         buy,
-			}
+			sig.amountOfShares = p.shares.round(newSharesAmount - sharesCurrentlyHeldAmount)
+			sig.action = buy
 		}
 		signals = append(signals, sig)
 	}
@@ -91,9 +139,12 @@ func (p *portfolio) generateSignals(newPositions []position, date time.Time) []s
 				date:           date,
 				company:        position.company,
 				price:          position.atPrice,
-				amountOfShares: position.amountOfShares,
+				amountOfShares: math.Abs(position.amountOfShares),
 				action:         sell,
 			}
+			if position.amountOfShares < 0 {
+				sig.action = buy
+			}
 			signals = append(signals, sig)
 		}
 	}
@@ -103,43 +154,135 @@ func (p *portfolio) generateSignals(newPositions []position, date time.Time) []s
 
 func (p *portfolio) patchPortfolio(signals []signal) error {
 	for _, signal := range signals {
-		p.performSignalAction(signal)
+		if signal.action == hold {
+			continue
+		}
+		p.fill(signal)
 	}
 
 	return nil
 }
 
-func (p *portfolio) performSignalAction(signal signal) {
-	switch signal.action {
-	case sell:
-		p.capital += float64(signal.amountOfShares)*(signal.price-p.commision.perShare) - p.commision.fixed
-		indexAt := indexAt(p.positions, signal.company.symbol)
-		if p.positions[indexAt].amountOfShares == signal.amountOfShares {
-			p.positions = remove(p.positions, indexAt)
-		} else {
-			p.positions[indexAt] = position{
-				company:        signal.company,
-				amountOfShares: p.positions[indexAt].amountOfShares - signal.amountOfShares,
-				atPrice:        signal.price,
-			}
+// fill executes order at its price, worsened by slippage unless the price
+// was guaranteed by a limit, and returns whether the order was settled.
+func (p *portfolio) fill(order signal) bool {
+	if order.orderType != limit && order.orderType != stopLimit {
+		order = p.applySlippage(order)
+	}
+	return p.performSignalAction(order)
+}
+
+// applySlippage moves price of buys up and of sells down by slippage
+// estimated for the order, keeping track of its total cost.
+func (p *portfolio) applySlippage(signal signal) signal {
+	if p.slippage == nil || signal.action == hold {
+		return signal
+	}
+	slippagePerShare := p.slippage.estimate(signal)
+	if slippage, err := p.toBase(signal.company, slippagePerShare*signal.amountOfShares, signal.date); err == nil {
+		p.paidSlippage += slippage
+	}
+	if signal.action == buy {
+		signal.price += slippagePerShare
+	} else {
+		signal.price -= slippagePerShare
+	}
+
+	return signal
+}
+
+var positionNotHeld = errors.New("cannot sell position which is not held when short selling is off")
+
+// performSignalAction settles order in cash and positions. Selling more than held
+// opens a short position when short selling is on, buying covers shorts first.
+// Orders in other than base currency are settled in cash converted at their date.
+// Returns whether the order was settled.
+func (p *portfolio) performSignalAction(signal signal) bool {
+	if signal.action != buy && signal.action != sell {
+		return false
+	}
+	fxRate, err := p.currency.fxRate(signal.company.profile.Currency, signal.date)
+	if err != nil {
+		log.Println(err, " "+signal.company.symbol)
+		return false
+	}
+	containsSymbol, indexAt := containsSymbol(p.positions, signal.company.symbol)
+	heldPosition := position{}
+	if containsSymbol {
+		heldPosition = p.positions[indexAt]
+	}
+	heldShares := heldPosition.amountOfShares
+
+	if signal.action == sell && heldShares-signal.amountOfShares < 0 && !p.allowsShorting() {
+		log.Println(positionNotHeld, " "+signal.company.symbol)
+		return false
+	}
+	increasesExposure := signal.action == buy && heldShares >= 0 || signal.action == sell && heldShares <= 0
+	if increasesExposure {
+		affordableShares := p.affordableShares(signal)
+		if affordableShares < signal.amountOfShares {
+			log.Printf("%s: %s of %s limited from %g to %g shares by available funds \n",
+				signal.date.Format(dateLayout), signal.action, signal.company.symbol, signal.amountOfShares, affordableShares)
+			signal.amountOfShares = affordableShares
 		}
-	case buy:
-		p.capital -= float64(signal.amountOfShares)*(signal.price+p.commision.perShare) + p.commision.fixed
-		containsSymbol, indexAt := containsSymbol(p.positions, signal.company.symbol)
-		if containsSymbol {
-			p.positions[indexAt] = position{
-				company:        signal.company,
-				amountOfShares: p.positions[indexAt].amountOfShares + signal.amountOfShares,
-				atPrice:        signal.price,
-			}
-		} else {
-			p.positions = append(p.positions, position{
-				company:        signal.company,
-				amountOfShares: signal.amountOfShares,
-				atPrice:        signal.price,
-			})
+		if signal.amountOfShares == 0 {
+			return false
 		}
 	}
+	sharesChange := signal.amountOfShares
+	if signal.action == sell {
+		sharesChange = -signal.amountOfShares
+	}
+
+	fee, err := p.baseCommision(signal)
+	if err != nil {
+		log.Println(err, " "+signal.company.symbol)
+		return false
+	}
+	recordVolume(p.commision, signal)
+	baseValue := sharesChange * signal.price * fxRate
+	fxFee := p.currency.orderConversionFee(signal.company.profile.Currency, baseValue, fee)
+	p.paidCommision += fee
+	p.paidFxFees += fxFee
+	p.tradedValue += math.Abs(baseValue)
+	p.capital -= baseValue + fee + fxFee
+	// Cost basis of tax lots is in base currency, fees at amounts paid
+	lotFxRate, err := p.lotFxRate(signal.company.profile.Currency, signal.date, fxRate)
+	if err != nil {
+		log.Println(err, " "+signal.company.symbol)
+		lotFxRate = fxRate
+	}
+	baseSignal := signal
+	baseSignal.price *= lotFxRate
+	lots := p.settleLots(heldPosition, baseSignal, sharesChange, fee+fxFee)
+
+	newShares := p.shares.round(heldShares + sharesChange)
+	switch {
+	case !containsSymbol:
+		p.positions = append(p.positions, position{
+			company:        signal.company,
+			amountOfShares: newShares,
+			atPrice:        signal.price,
+			lots:           lots,
+		})
+		p.onPositionOpened(signal)
+	case newShares == 0:
+		p.positions = remove(p.positions, indexAt)
+		p.onPositionClosed(signal)
+	default:
+		p.positions[indexAt] = position{
+			company:        signal.company,
+			amountOfShares: newShares,
+			atPrice:        signal.price,
+			lots:           lots,
+		}
+		// Position flipped from long to short or the other way around
+		if (heldShares > 0) != (newShares > 0) {
+			p.onPositionClosed(signal)
+			p.onPositionOpened(signal)
+		}
+	}
+	return true
 }
 
 func indexAt(positions []position, symbol string) int {
@@ -156,14 +299,29 @@ func remove(s []position, index int) []position {
 	return s[:len(s)-1]
 }
 
-func (p *portfolio) calculateNewPositions(topCompanies []companyInfo, date time.Time) ([]position, error) {
+// calculateNewPositions sizes long positions in top companies and short positions
+// in bottom companies. Weights are applied to the part of portfolio value top
+// companies would get with equal split among portfolio size, so fewer top
+// companies than portfolio size, e.g. due to sector limits, leave cash aside.
+func (p *portfolio) calculateNewPositions(topCompanies []companyInfo, bottomCompanies []companyInfo, scores map[string]float64, date time.Time) ([]position, error) {
 	portfolioValue, err := p.calculatePortfolioValue(date)
 	if err != nil {
 		return nil, err
 	}
+	investedValue := portfolioValue * float64(len(topCompanies)) / float64(p.size)
+	currentWeights, err := p.calculateCurrentWeights(investedValue, date)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := p.weighting.calculateWeights(topCompanies, scores, currentWeights, date)
+	if err != nil {
+		return nil, err
+	}
+	weights = p.sectorLimits.capWeights(topCompanies, weights)
+
 	positions := make([]position, 0)
-	for _, topCompany := range topCompanies {
-		amountOfShares, price := p.calculateAmountAndPriceOfShares(topCompany, portfolioValue, date)
+	for i, topCompany := range topCompanies {
+		amountOfShares, price := p.calculateAmountAndPriceOfShares(topCompany, investedValue*weights[i], date)
 		positions = append(positions, position{
 			company:        topCompany,
 			amountOfShares: amountOfShares,
@@ -171,7 +329,12 @@ func (p *portfolio) calculateNewPositions(topCompanies []companyInfo, date time.
 		})
 	}
 
-	return positions, nil
+	shortPositions, err := p.calculateShortPositions(bottomCompanies, positions, portfolioValue, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(positions, shortPositions...), nil
 }
 
 func contains(positions []position, position position) (bool, int) {
@@ -200,20 +363,84 @@ func (p *portfolio) calculatePortfolioValue(date time.Time) (float64, error) {
 	var positionsValue float64
 
 	for _, position := range p.positions {
-		priceIndex, err := determinePriceIndexForDate(position.company.historicalPrice.Historical, date)
+		positionValue, err := p.positionValue(position, date)
 		if err != nil {
 			return 0, portfolioCalculationError
 		}
-		positionsValue += position.company.historicalPrice.Historical[priceIndex].Close * float64(position.amountOfShares)
+		positionsValue += positionValue
 	}
 
 	return positionsValue + p.capital, nil
 }
 
-func (p *portfolio) calculateAmountAndPriceOfShares(company companyInfo, portfolioValue float64, date time.Time) (int, float64) {
-	valueGrantedPerCompany := portfolioValue / float64(p.size)
-	priceIndex, _ := determinePriceIndexForDate(company.historicalPrice.Historical, date)
-	price := company.historicalPrice.Historical[priceIndex].Close
+// calculateCurrentWeights returns value of held positions relative to given value.
+func (p *portfolio) calculateCurrentWeights(relativeTo float64, date time.Time) (map[string]float64, error) {
+	currentWeights := make(map[string]float64, len(p.positions))
+	if relativeTo == 0 {
+		return currentWeights, nil
+	}
+
+	for _, position := range p.positions {
+		positionValue, err := p.positionValue(position, date)
+		if err != nil {
+			return nil, portfolioCalculationError
+		}
+		currentWeights[position.company.symbol] = positionValue / relativeTo
+	}
+
+	return currentWeights, nil
+}
+
+// Calendar days searched back for a trading day, covering weekends and holidays.
+const maxDaysWithoutTrading = 7
+
+// calculatePortfolioValueAtOrBefore values portfolio on given date or, if it
+// cannot be valued then (e.g. a price is missing), on the closest day before it.
+func (p *portfolio) calculatePortfolioValueAtOrBefore(date time.Time) (float64, error) {
+	for days := 0; days < maxDaysWithoutTrading; days++ {
+		value, err := p.calculatePortfolioValue(date.AddDate(0, 0, -days))
+		if err == nil {
+			return value, nil
+		}
+	}
+	return 0, portfolioCalculationError
+}
+
+// positionsTradedOn tells whether every held company has a bar of date.
+func (p *portfolio) positionsTradedOn(date time.Time) bool {
+	for _, position := range p.positions {
+		priceHistory := position.company.historicalPrice.Historical
+		priceIndex, err := determinePriceIndexForDate(priceHistory, date)
+		if err != nil || priceHistory[priceIndex].Date != date.Format(dateLayout) {
+			return false
+		}
+	}
+	return true
+}
+
+// positionValue is value of position in base currency at close price on date
+// or, on days its company did not trade, at the last close before it.
+func (p *portfolio) positionValue(position position, date time.Time) (float64, error) {
+	price, err := closePriceAtOrBefore(position.company, date)
+	if err != nil {
+		return 0, err
+	}
+	return p.toBase(position.company, price*position.amountOfShares, date)
+}
+
+// calculateAmountAndPriceOfShares returns the largest tradable amount of shares
+// granted value in base currency buys at close price in instrument currency.
+func (p *portfolio) calculateAmountAndPriceOfShares(company companyInfo, valueGrantedPerCompany float64, date time.Time) (float64, float64) {
+	price, err := closePriceAtOrBefore(company, date)
+	if err != nil {
+		log.Println(err, " "+company.symbol)
+		return 0, 0
+	}
+	basePrice, err := p.toBase(company, price, date)
+	if err != nil {
+		log.Println(err, " "+company.symbol)
+		return 0, price
+	}
 
-	return int(valueGrantedPerCompany / price), price
+	return p.shares.roundDown(valueGrantedPerCompany / basePrice), price
 }
@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"errors"
+	"math"
+	"time"
+)
 
 func determinePriceIndexForDate(priceHistory []Price, date time.Time) (int, error) {
 	for index, price := range priceHistory {
@@ -24,3 +28,88 @@ func determinePriceIndexForDate(priceHistory []Price, date time.Time) (int, erro
 	}
 	return -1, dateIndexNotFound
 }
+
+// determinePriceIndexAtOrBefore returns index of the price on date or, if there
+// was none (e.g. on a weekend), of the closest one before it. Unlike
+// determinePriceIndexForDate it never resolves a date to a later trading day.
+func determinePriceIndexAtOrBefore(priceHistory []Price, date time.Time) (int, error) {
+	earliest := date.AddDate(0, 0, -maxDaysWithoutTrading)
+	for index, price := range priceHistory {
+		priceDateFormatted, err := time.Parse(dateLayout, price.Date)
+		if err != nil {
+			break
+		}
+		if priceDateFormatted.After(date) {
+			continue
+		}
+		if priceDateFormatted.After(earliest) {
+			return index, nil
+		}
+		break
+	}
+	return -1, dateIndexNotFound
+}
+
+var priceHistoryOutOfBounds = errors.New("price history does not cover requested period")
+
+// getPrices returns prices of given number of trading days
+// preceding date plus the price on date itself, most recent first.
+func getPrices(priceHistory []Price, date time.Time, days int) ([]Price, error) {
+	startingIndex, err := determinePriceIndexForDate(priceHistory, date)
+	if err != nil {
+		return nil, err
+	}
+	if startingIndex+days+1 > len(priceHistory) {
+		return nil, priceHistoryOutOfBounds
+	}
+
+	return priceHistory[startingIndex : startingIndex+days+1], nil
+}
+
+// getClosePrices returns close prices of given number of trading days
+// preceding date plus the close on date itself, most recent first.
+func getClosePrices(priceHistory []Price, date time.Time, days int) ([]float64, error) {
+	prices, err := getPrices(priceHistory, date, days)
+	if err != nil {
+		return nil, err
+	}
+
+	closes := make([]float64, 0, days+1)
+	for _, price := range prices {
+		closes = append(closes, price.Close)
+	}
+
+	return closes, nil
+}
+
+// dailyReturns converts close prices, most recent first, into simple
+// daily returns, also most recent first.
+func dailyReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return make([]float64, 0)
+	}
+	returns := make([]float64, len(closes)-1)
+	for i := range returns {
+		returns[i] = closes[i]/closes[i+1] - 1
+	}
+
+	return returns
+}
+
+// averageTrueRange returns mean true range of given number of trading days
+// up to and including date.
+func averageTrueRange(priceHistory []Price, date time.Time, days int) (float64, error) {
+	prices, err := getPrices(priceHistory, date, days)
+	if err != nil {
+		return 0, err
+	}
+
+	trueRanges := make([]float64, days)
+	for i := range trueRanges {
+		previousClose := prices[i+1].Close
+		trueRanges[i] = math.Max(prices[i].High-prices[i].Low,
+			math.Max(math.Abs(prices[i].High-previousClose), math.Abs(prices[i].Low-previousClose)))
+	}
+
+	return Sma(trueRanges...), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ranking records how every candidate company was evaluated on a rebalance
+// date, so it can be audited why a company was or was not bought.
+type ranking struct {
+	date time.Time
+	rows []rankingRow
+}
+
+type rankingRow struct {
+	symbol            string
+	rawResults        []float64
+	normalizedResults []float64
+	finalResult       float64
+	// Position in ranking starting from 1, 0 for dropped companies.
+	rank          int
+	selected      bool
+	shorted       bool
+	droppedReason string
+}
+
+func newRanking(
+	date time.Time,
+	rawResults []criteriaEvaluationResult,
+	normalizedResults []criteriaEvaluationResult,
+	finalResults map[string]float64,
+	selectedCompanies []companyInfo,
+	droppedResults []criteriaEvaluationResult) ranking {
+
+	rows := make([]rankingRow, 0, len(rawResults)+len(droppedResults))
+
+	for rank, symbol := range rankSymbols(finalResults) {
+		row := rankingRow{
+			symbol:      symbol,
+			finalResult: finalResults[symbol],
+			rank:        rank + 1,
+		}
+		for i, rawResult := range rawResults {
+			if rawResult.companySymbol == symbol {
+				row.rawResults = rawResult.results
+				row.normalizedResults = normalizedResults[i].results
+			}
+		}
+		for _, company := range selectedCompanies {
+			if company.symbol == symbol {
+				row.selected = true
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	for _, droppedResult := range droppedResults {
+		rows = append(rows, rankingRow{
+			symbol:        droppedResult.companySymbol,
+			droppedReason: droppedResult.error.Error(),
+		})
+	}
+
+	return ranking{date, rows}
+}
+
+func (r ranking) markShorted(symbol string) {
+	for i := range r.rows {
+		if r.rows[i].symbol == symbol {
+			r.rows[i].shorted = true
+		}
+	}
+}
+
+// writeRankingsCSV writes one line per company per rebalance date, with raw
+// and normalized result columns for every criterion of the strategy.
+func writeRankingsCSV(w io.Writer, rankings []ranking, criteria []criterion) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"date", "symbol", "rank", "selected", "shorted", "final"}
+	for _, criterion := range criteria {
+		header = append(header, "raw_"+criterion.criterionType)
+	}
+	for _, criterion := range criteria {
+		header = append(header, "normalized_"+criterion.criterionType)
+	}
+	header = append(header, "dropped_reason")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, ranking := range rankings {
+		for _, row := range ranking.rows {
+			record := []string{
+				ranking.date.Format(dateLayout),
+				row.symbol,
+				strconv.Itoa(row.rank),
+				strconv.FormatBool(row.selected),
+				strconv.FormatBool(row.shorted),
+				formatFloat(row.finalResult),
+			}
+			record = append(record, formatResults(row.rawResults, len(criteria))...)
+			record = append(record, formatResults(row.normalizedResults, len(criteria))...)
+			record = append(record, row.droppedReason)
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatResults(results []float64, amount int) []string {
+	formatted := make([]string, amount)
+	for i, result := range results {
+		formatted[i] = formatFloat(result)
+	}
+	return formatted
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
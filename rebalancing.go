@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// rebalancing configures which adjustments of held positions are worth
+// trading. Entries of new positions and exits of dropped ones always trade.
+type rebalancing struct {
+	// Drift of weight from target weight, in weight points, within which
+	// held position is not adjusted, e.g. 0.02 holds position of target
+	// weight 0.1 between weights 0.08 and 0.12.
+	absoluteBand float64
+	// Drift of weight relative to target weight within which held position
+	// is not adjusted, e.g. 0.25 holds position of target weight 0.1
+	// between weights 0.075 and 0.125.
+	relativeBand float64
+	// Adjustments worth less are not traded.
+	minTradeValue float64
+	// Held positions are never adjusted, only entered and exited.
+	onlyEntriesAndExits bool
+}
+
+// holds tells whether adjustment of held shares to target shares is not worth trading.
+func (r rebalancing) holds(heldShares float64, targetShares float64, price float64, portfolioValue float64) bool {
+	if r.onlyEntriesAndExits {
+		return true
+	}
+	if math.Abs(targetShares-heldShares)*price < r.minTradeValue {
+		return true
+	}
+	if portfolioValue <= 0 {
+		return false
+	}
+
+	weight := heldShares * price / portfolioValue
+	targetWeight := targetShares * price / portfolioValue
+	drift := math.Abs(weight - targetWeight)
+	if r.absoluteBand > 0 && drift <= r.absoluteBand {
+		return true
+	}
+	return r.relativeBand > 0 && drift <= r.relativeBand*math.Abs(targetWeight)
+}
+
+// isAdjustment tells whether position is resized, rather than entered, exited or flipped.
+func isAdjustment(heldShares float64, targetShares float64) bool {
+	return heldShares != 0 && targetShares != 0 && math.Signbit(heldShares) == math.Signbit(targetShares)
+}
+
+// recordRebalance keeps portfolio value turnover is measured against.
+func (p *portfolio) recordRebalance(date time.Time) float64 {
+	portfolioValue, err := p.calculatePortfolioValue(date)
+	if err != nil {
+		return 0
+	}
+	p.rebalanceValues = append(p.rebalanceValues, portfolioValue)
+	return portfolioValue
+}
+
+// turnover is one-way turnover, value traded in buys and sells halved,
+// relative to average portfolio value on rebalance dates.
+func (p *portfolio) turnover() float64 {
+	if len(p.rebalanceValues) == 0 {
+		return 0
+	}
+	var averageValue float64
+	for _, value := range p.rebalanceValues {
+		averageValue += value / float64(len(p.rebalanceValues))
+	}
+	if averageValue == 0 {
+		return 0
+	}
+	return p.tradedValue / 2 / averageValue
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+)
+
+func portfolioHoldingWeekly(rules rebalancing) portfolio {
+	portfolio := portfolio{commision: commision{}, capital: 9000, rebalancing: rules}
+	portfolio.positions = []position{{company: weekly, amountOfShares: 10, atPrice: 100}}
+	return portfolio
+}
+
+func TestRebalancing_holds_drift_within_band(t *testing.T) {
+	for _, rules := range []rebalancing{{absoluteBand: 0.01}, {relativeBand: 0.1}, {minTradeValue: 200}, {onlyEntriesAndExits: true}} {
+		// Given
+		portfolio := portfolioHoldingWeekly(rules)
+
+		// When
+		signals := portfolio.generateSignals([]position{{company: weekly, amountOfShares: 11, atPrice: 100}}, friday)
+
+		// Then
+		if signals[0].action != hold || signals[0].amountOfShares != 10 || portfolio.skippedAdjustments != 1 {
+			t.Fatalf("expected adjustment to be held with %+v, actual signal: %+v", rules, signals[0])
+		}
+	}
+}
+
+func TestRebalancing_trades_drift_outside_band(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingWeekly(rebalancing{absoluteBand: 0.01, relativeBand: 0.05})
+
+	// When
+	signals := portfolio.generateSignals([]position{{company: weekly, amountOfShares: 12, atPrice: 100}}, friday)
+
+	// Then
+	if signals[0].action != buy || signals[0].amountOfShares != 2 {
+		t.Fatalf("expected buy of 2 shares, actual signal: %+v", signals[0])
+	}
+}
+
+func TestRebalancing_exits_despite_only_entries_and_exits(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingWeekly(rebalancing{onlyEntriesAndExits: true, minTradeValue: 10_000})
+
+	// When
+	signals := portfolio.generateSignals([]position{}, friday)
+
+	// Then
+	if signals[0].action != sell || signals[0].amountOfShares != 10 {
+		t.Fatalf("expected exit of 10 shares, actual signal: %+v", signals[0])
+	}
+}
+
+func TestRebalancing_turnover(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingWeekly(rebalancing{})
+	signals := portfolio.generateSignals([]position{{company: apple, amountOfShares: 10, atPrice: 100}}, friday)
+
+	// When
+	portfolio.patchPortfolio(signals)
+
+	// Then
+	assertFloat(t, 2000, portfolio.tradedValue)
+	assertFloat(t, 0.1, portfolio.turnover())
+}
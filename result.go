@@ -0,0 +1,83 @@
+package main
+
+import "time"
+
+// backtestResult summarizes a finished backtest.
+type backtestResult struct {
+	finalValue float64
+	// Time weighted return in base currency of portfolio.
+	totalReturn float64
+	// Annual internal rate of return of cash invested.
+	moneyWeightedReturn float64
+	// Net of deposits and withdrawals.
+	netCashFlow float64
+	// Return measured in every instrument currency, excluding
+	// effect of its exchange rate to base currency.
+	returnsInCurrencies map[string]float64
+	paidCommision       float64
+	paidSlippage        float64
+	paidBorrowFees      float64
+	paidInterest        float64
+	earnedInterest      float64
+	marginCalls         int
+	orderStats          orderStats
+	// Fraction of placed orders which were filled.
+	fillRate     float64
+	realizedGain float64
+	paidTax      float64
+	// Final value less capital gains tax due for the last, unsettled year.
+	afterTaxValue float64
+	tradedValue   float64
+	// One-way turnover relative to average portfolio value on rebalance dates.
+	turnover float64
+	// Adjustments of held positions skipped by rebalancing rules.
+	skippedAdjustments int
+	paidFxFees         float64
+	// Attribution of holdings of rebalance snapshots only, ignoring trades
+	// between them, against the equally weighted universe of companies.
+	attribution attribution
+	equityCurve []equityPoint
+	cashFlows   []appliedCashFlow
+	metrics     metrics
+}
+
+func (p *portfolio) summarize(startValue float64, finalValue float64, from time.Time, to time.Time) backtestResult {
+	taxDue, _ := p.capitalGainsTax(p.taxYear)
+	totalReturn := p.timeWeightedReturn(startValue, finalValue)
+	var netCashFlow float64
+	for _, flow := range p.appliedCashFlows {
+		netCashFlow += flow.amount
+	}
+	return backtestResult{
+		finalValue:          finalValue,
+		totalReturn:         totalReturn,
+		moneyWeightedReturn: p.moneyWeightedReturn(startValue, finalValue, from, to),
+		netCashFlow:         netCashFlow,
+		returnsInCurrencies: p.currency.returnsInCurrencies(totalReturn, from, to),
+		paidCommision:       p.paidCommision,
+		paidSlippage:        p.paidSlippage,
+		paidBorrowFees:      p.paidBorrowFees,
+		paidInterest:        p.paidInterest,
+		earnedInterest:      p.earnedInterest,
+		marginCalls:         p.marginCalls,
+		orderStats:          p.orderStats,
+		fillRate:            p.orderStats.fillRate(),
+		realizedGain:        p.totalRealizedGain(),
+		paidTax:             p.paidTax,
+		afterTaxValue:       finalValue - taxDue,
+		tradedValue:         p.tradedValue,
+		turnover:            p.turnover(),
+		skippedAdjustments:  p.skippedAdjustments,
+		paidFxFees:          p.paidFxFees,
+		equityCurve:         p.equityCurve,
+		cashFlows:           p.appliedCashFlows,
+		metrics:             calculateMetrics(p.equityCurve, p.appliedCashFlows, p.turnover()),
+	}
+}
+
+func (s orderStats) fillRate() float64 {
+	if s.placed == 0 {
+		return 0
+	}
+	return float64(s.filled) / float64(s.placed)
+}
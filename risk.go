@@ -0,0 +1,173 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Risk rule exit reasons
+const (
+	stopLossReason     = "STOP_LOSS"
+	atrStopReason      = "ATR_STOP"
+	trailingStopReason = "TRAILING_STOP"
+	takeProfitReason   = "TAKE_PROFIT"
+)
+
+const defaultAtrPeriod = 14
+
+// riskRules close positions between rebalances. Rules set to 0 are not used.
+type riskRules struct {
+	// Fraction of entry price the price may fall by.
+	stopLoss float64
+	// Multiple of average true range at entry the price may fall by.
+	atrStop   float64
+	atrPeriod int
+	// Fraction of the highest price since entry the price may fall by.
+	trailingStop float64
+	// Fraction of entry price the price has to rise by to take profit.
+	takeProfit float64
+}
+
+// riskState is what risk rules need to know about a held position.
+// Rules of short positions are mirrored: stops are above the price
+// and the high water mark is the lowest price since entry.
+type riskState struct {
+	entryDate  time.Time
+	entryPrice float64
+	entryAtr   float64
+	highWater  float64
+	short      bool
+}
+
+func (r riskRules) enabled() bool {
+	return r.stopLoss > 0 || r.atrStop > 0 || r.trailingStop > 0 || r.takeProfit > 0
+}
+
+// onPositionOpened starts tracking a new position for selection and risk rules.
+func (p *portfolio) onPositionOpened(order signal) {
+	p.recordEntry(order)
+	if !p.riskRules.enabled() {
+		return
+	}
+	if p.riskStates == nil {
+		p.riskStates = make(map[string]riskState)
+	}
+
+	state := riskState{
+		entryDate:  order.date,
+		entryPrice: order.price,
+		highWater:  order.price,
+		short:      order.action == sell,
+	}
+	if p.riskRules.atrStop > 0 {
+		atrPeriod := p.riskRules.atrPeriod
+		if atrPeriod == 0 {
+			atrPeriod = defaultAtrPeriod
+		}
+		atr, err := averageTrueRange(order.company.historicalPrice.Historical, order.date, atrPeriod)
+		if err != nil {
+			log.Printf("error while calculating ATR of %s: %s \n", order.company.symbol, err)
+		}
+		state.entryAtr = atr
+	}
+	p.riskStates[order.company.symbol] = state
+}
+
+func (p *portfolio) onPositionClosed(order signal) {
+	p.recordExit(order)
+	delete(p.riskStates, order.company.symbol)
+}
+
+// checkRiskRules sells positions whose stop or take profit level was reached
+// within the bar of date. Stops are checked first, as it cannot be told whether
+// High or Low came first within the day and assuming the worse is safer.
+func (p *portfolio) checkRiskRules(date time.Time) {
+	if !p.riskRules.enabled() {
+		return
+	}
+
+	exits := make([]signal, 0)
+	for _, position := range p.positions {
+		state, tracked := p.riskStates[position.company.symbol]
+		if !tracked {
+			continue
+		}
+		priceHistory := position.company.historicalPrice.Historical
+		priceIndex, err := determinePriceIndexForDate(priceHistory, date)
+		if err != nil || priceHistory[priceIndex].Date != date.Format(dateLayout) || !date.After(state.entryDate) {
+			continue
+		}
+		bar := priceHistory[priceIndex]
+
+		exit := signal{
+			date:           date,
+			company:        position.company,
+			amountOfShares: math.Abs(position.amountOfShares),
+			action:         sell,
+		}
+		// For shorts the adverse extreme of the bar is High and the favourable
+		// one is Low, and fills worse for the position are the higher ones.
+		adverse, favourable := bar.Low, bar.High
+		worse, better := math.Min, math.Max
+		if state.short {
+			exit.action = buy
+			adverse, favourable = bar.High, bar.Low
+			worse, better = math.Max, math.Min
+		}
+
+		stopLevel, stopReason := p.riskRules.stopLevel(state)
+		takeProfitLevel := state.entryPrice * (1 + state.sign()*p.riskRules.takeProfit)
+		switch {
+		case stopReason != "" && worse(adverse, stopLevel) == adverse:
+			exit.orderType, exit.reason = stop, stopReason
+			exit.price = worse(bar.Open, stopLevel)
+		case p.riskRules.takeProfit > 0 && better(favourable, takeProfitLevel) == favourable:
+			exit.orderType, exit.reason = limit, takeProfitReason
+			exit.price = better(bar.Open, takeProfitLevel)
+		default:
+			state.highWater = better(state.highWater, favourable)
+			p.riskStates[position.company.symbol] = state
+			continue
+		}
+		exits = append(exits, exit)
+	}
+
+	for _, exit := range exits {
+		log.Printf("%s: %s exit of %s at %.2f \n", date.Format(dateLayout), exit.reason, exit.company.symbol, exit.price)
+		p.cancelPendingOrdersOf(exit.company.symbol)
+		p.fill(exit)
+		p.riskExits = append(p.riskExits, exit)
+	}
+}
+
+// stopLevel returns the tightest of stop levels set by the rules,
+// along with the reason of the rule setting it.
+func (r riskRules) stopLevel(state riskState) (float64, string) {
+	sign := state.sign()
+	level, reason := math.Inf(-1), ""
+	tighter := func(candidate float64, candidateReason string) {
+		// Comparing sign adjusted levels, as for shorts the lowest stop is the tightest
+		if sign*candidate > level {
+			level, reason = sign*candidate, candidateReason
+		}
+	}
+	if r.stopLoss > 0 {
+		tighter(state.entryPrice*(1-sign*r.stopLoss), stopLossReason)
+	}
+	if r.atrStop > 0 && state.entryAtr > 0 {
+		tighter(state.entryPrice-sign*r.atrStop*state.entryAtr, atrStopReason)
+	}
+	if r.trailingStop > 0 {
+		tighter(state.highWater*(1-sign*r.trailingStop), trailingStopReason)
+	}
+	return sign * level, reason
+}
+
+// sign is 1 for long positions and -1 for short ones.
+func (s riskState) sign() float64 {
+	if s.short {
+		return -1
+	}
+	return 1
+}
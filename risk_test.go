@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var entryDate, _ = time.Parse(dateLayout, "2021-01-04")
+
+var trending = companyInfo{
+	symbol: "TRND",
+	historicalPrice: HistoricalPrice{
+		Symbol: "TRND",
+		Historical: []Price{
+			{Date: "2021-01-07", Open: 108, High: 109, Low: 99, Close: 100},
+			{Date: "2021-01-06", Open: 106, High: 112, Low: 105, Close: 110},
+			{Date: "2021-01-05", Open: 101, High: 106, Low: 100, Close: 105},
+			{Date: "2021-01-04", Open: 100, High: 101, Low: 99, Close: 100},
+		},
+	},
+}
+
+func portfolioHoldingTrending(rules riskRules) portfolio {
+	portfolio := portfolio{commision: commision{}, capital: 1000, riskRules: rules}
+	portfolio.submitOrders([]signal{{date: entryDate, company: trending, price: 100, amountOfShares: 10, action: buy}})
+	return portfolio
+}
+
+func checkRiskRulesUntil(portfolio *portfolio, until time.Time) {
+	for date := entryDate; !date.After(until); date = date.AddDate(0, 0, 1) {
+		portfolio.checkRiskRules(date)
+	}
+}
+
+func TestRisk_trailing_stop_follows_highest_price(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingTrending(riskRules{stopLoss: 0.05, trailingStop: 0.1})
+
+	// When
+	checkRiskRulesUntil(&portfolio, entryDate.AddDate(0, 0, 3))
+
+	// Then
+	if len(portfolio.positions) != 0 || len(portfolio.riskExits) != 1 {
+		t.Fatalf("expected position to be closed by risk rules, actual portfolio: %+v", portfolio)
+	}
+	exit := portfolio.riskExits[0]
+	if exit.reason != trailingStopReason || exit.date.Format(dateLayout) != "2021-01-07" {
+		t.Fatalf("expected trailing stop exit on 2021-01-07, actual exit: %s on %s", exit.reason, exit.date.Format(dateLayout))
+	}
+	assertFloat(t, 112*0.9, exit.price)
+	assertFloat(t, 10*112*0.9, portfolio.capital)
+	expectedStats := orderStats{placed: 1, filled: 1}
+	if portfolio.orderStats != expectedStats {
+		t.Fatalf("expected risk exit not to count as order, actual order stats: %+v", portfolio.orderStats)
+	}
+}
+
+func TestRisk_take_profit(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingTrending(riskRules{stopLoss: 0.05, takeProfit: 0.1})
+
+	// When
+	checkRiskRulesUntil(&portfolio, entryDate.AddDate(0, 0, 3))
+
+	// Then
+	if len(portfolio.riskExits) != 1 || portfolio.riskExits[0].reason != takeProfitReason {
+		t.Fatalf("expected take profit exit, actual exits: %+v", portfolio.riskExits)
+	}
+	assertFloat(t, 110, portfolio.riskExits[0].price)
+}
+
+func TestRisk_stop_loss_fills_at_open_when_gapped(t *testing.T) {
+	// Given
+	portfolio := portfolioHoldingTrending(riskRules{stopLoss: 0.01})
+	portfolio.riskStates["TRND"] = riskState{entryDate: entryDate, entryPrice: 110, highWater: 110}
+
+	// When
+	checkRiskRulesUntil(&portfolio, entryDate.AddDate(0, 0, 1))
+
+	// Then
+	if len(portfolio.riskExits) != 1 || portfolio.riskExits[0].reason != stopLossReason {
+		t.Fatalf("expected stop loss exit, actual exits: %+v", portfolio.riskExits)
+	}
+	assertFloat(t, 101, portfolio.riskExits[0].price)
+}
@@ -66,4 +66,73 @@ func (s smaStrategy) perform(companyInfos []companyInfo, direction string, scree
 	return result
 }
 
+// marketCapStrategy screens companies by market capitalization
+// being above or below threshold.
+type marketCapStrategy struct {
+	threshold float64
+}
+
+func (s marketCapStrategy) perform(companyInfos []companyInfo, direction string, _ int, date time.Time) []companyInfo {
+	result := make([]companyInfo, 0)
+
+	for _, company := range companyInfos {
+		marketCap, err := getMarketCap(company, date)
+		if err != nil {
+			log.Printf("error while screening %s: %s \n", company.symbol, err)
+			continue
+		}
+		if passesThreshold(marketCap, s.threshold, direction) {
+			result = append(result, company)
+		}
+	}
+
+	return result
+}
+
+// ratioStrategy screens companies by one of financial ratios
+// (e.g. debtToEquity) being above or below threshold.
+type ratioStrategy struct {
+	ratio     string
+	threshold float64
+}
+
+func (s ratioStrategy) perform(companyInfos []companyInfo, direction string, _ int, date time.Time) []companyInfo {
+	result := make([]companyInfo, 0)
+
+	for _, company := range companyInfos {
+		ratio, err := getRatio(company, s.ratio, date)
+		if err != nil {
+			log.Printf("error while screening %s: %s \n", company.symbol, err)
+			continue
+		}
+		if passesThreshold(ratio, s.threshold, direction) {
+			result = append(result, company)
+		}
+	}
+
+	return result
+}
+
+// compositeStrategy screens companies with every screener in turn,
+// so only companies passing all of them remain. Direction and period
+// of each inner screener are used instead of the outer ones.
+type compositeStrategy struct {
+	screeners []screener
+}
+
+func (s compositeStrategy) perform(companyInfos []companyInfo, _ string, _ int, date time.Time) []companyInfo {
+	result := companyInfos
+
+	for _, screener := range s.screeners {
+		result = screener.screen(result, date)
+	}
+
+	return result
+}
+
+func passesThreshold(value float64, threshold float64, direction string) bool {
+	return direction == above && value > threshold ||
+		direction == below && value < threshold
+}
+
 var dateIndexNotFound = errors.New("Date index could not be determined")
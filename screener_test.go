@@ -218,3 +218,71 @@ func TestScreen_companies_for_no_company_data(t *testing.T) {
 		t.Fatalf("expected companies: %+v\\n, actual companies: %+v\\n", companies, companiesAfterScreening)
 	}
 }
+
+// ################# Fundamental screener tests #################
+var largeLowDebt = companyInfo{
+	symbol:    "LRGE",
+	ratios:    []FinancialRatio{{Date: "2020-12-31", DebtEquityRatio: 0.4}},
+	marketCap: []MarketCapitalization{{Date: "2021-01-20", MarketCap: 50e9}},
+}
+var largeHighDebt = companyInfo{
+	symbol:    "DEBT",
+	ratios:    []FinancialRatio{{Date: "2020-12-31", DebtEquityRatio: 2.5}},
+	marketCap: []MarketCapitalization{{Date: "2021-01-19", MarketCap: 20e9}},
+}
+var smallLowDebt = companyInfo{
+	symbol:    "SMLL",
+	ratios:    []FinancialRatio{{Date: "2020-12-31", DebtEquityRatio: 0.1}},
+	marketCap: []MarketCapitalization{{Date: "2021-01-20", MarketCap: 2e9}},
+}
+var staleRatios = companyInfo{
+	symbol:    "STLE",
+	ratios:    []FinancialRatio{{Date: "2020-06-30", DebtEquityRatio: 0.1}},
+	marketCap: []MarketCapitalization{{Date: "2021-01-20", MarketCap: 30e9}},
+}
+
+func TestScreen_companies_for_market_cap_above_10B(t *testing.T) {
+	// Given
+	var companies = []companyInfo{largeLowDebt, largeHighDebt, smallLowDebt}
+	expectedCompanies := []companyInfo{largeLowDebt, largeHighDebt}
+
+	// When
+	companiesAfterScreening := marketCapStrategy{threshold: 10e9}.perform(companies, above, 0, date)
+
+	// Then
+	if !reflect.DeepEqual(expectedCompanies, companiesAfterScreening) {
+		t.Fatalf("expected companies: %+v\\n, actual companies: %+v\\n", expectedCompanies, companiesAfterScreening)
+	}
+}
+
+func TestScreen_companies_for_debt_to_equity_below_1_skips_stale_reports(t *testing.T) {
+	// Given
+	var companies = []companyInfo{largeLowDebt, largeHighDebt, staleRatios}
+	expectedCompanies := []companyInfo{largeLowDebt}
+
+	// When
+	companiesAfterScreening := ratioStrategy{ratio: debtToEquity, threshold: 1}.perform(companies, below, 0, date)
+
+	// Then
+	if !reflect.DeepEqual(expectedCompanies, companiesAfterScreening) {
+		t.Fatalf("expected companies: %+v\\n, actual companies: %+v\\n", expectedCompanies, companiesAfterScreening)
+	}
+}
+
+func TestScreen_companies_for_market_cap_above_10B_and_debt_to_equity_below_1(t *testing.T) {
+	// Given
+	var companies = []companyInfo{largeLowDebt, largeHighDebt, smallLowDebt}
+	expectedCompanies := []companyInfo{largeLowDebt}
+	composite := compositeStrategy{screeners: []screener{
+		{direction: above, screeningStrategy: marketCapStrategy{threshold: 10e9}},
+		{direction: below, screeningStrategy: ratioStrategy{ratio: debtToEquity, threshold: 1}},
+	}}
+
+	// When
+	companiesAfterScreening := composite.perform(companies, "", 0, date)
+
+	// Then
+	if !reflect.DeepEqual(expectedCompanies, companiesAfterScreening) {
+		t.Fatalf("expected companies: %+v\\n, actual companies: %+v\\n", expectedCompanies, companiesAfterScreening)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+// sectorLimits constrain exposure of long positions to a single sector.
+// Companies of unknown sector are not constrained.
+type sectorLimits struct {
+	// Companies selected per sector, the worse ranked ones give way
+	// to the next ranked companies of other sectors.
+	maxNames int
+	// Fraction of invested value per sector, excess is redistributed
+	// to companies of other sectors in proportion to their weights.
+	maxWeight float64
+}
+
+// admits tells whether another company of sector can be selected,
+// given the amount of companies already selected per sector.
+func (l sectorLimits) admits(sector string, namesPerSector map[string]int) bool {
+	return l.maxNames <= 0 || sector == "" || namesPerSector[sector] < l.maxNames
+}
+
+// capWeights scales weights of companies of every sector over the limit down
+// to it. When every sector is capped the excess is left in cash.
+func (l sectorLimits) capWeights(companies []companyInfo, weights []float64) []float64 {
+	if l.maxWeight <= 0 {
+		return weights
+	}
+
+	capped := make(map[string]bool)
+	for {
+		sectorWeights := make(map[string]float64)
+		for i, company := range companies {
+			sectorWeights[company.profile.Sector] += weights[i]
+		}
+
+		var excess, uncappedSum float64
+		for i, company := range companies {
+			sector := company.profile.Sector
+			switch {
+			case sector != "" && sectorWeights[sector] > l.maxWeight:
+				scaled := weights[i] * l.maxWeight / sectorWeights[sector]
+				excess += weights[i] - scaled
+				weights[i] = scaled
+				capped[sector] = true
+			case sector == "" || !capped[sector]:
+				uncappedSum += weights[i]
+			}
+		}
+		if excess <= 1e-12 || uncappedSum == 0 {
+			return weights
+		}
+		for i, company := range companies {
+			if sector := company.profile.Sector; sector == "" || !capped[sector] {
+				weights[i] += excess * weights[i] / uncappedSum
+			}
+		}
+	}
+}
+
+// sectorsOf maps symbols of companies to their sectors.
+func sectorsOf(companies []companyInfo) map[string]string {
+	sectors := make(map[string]string, len(companies))
+	for _, company := range companies {
+		sectors[company.symbol] = company.profile.Sector
+	}
+	return sectors
+}
+
+// normalizeWithinSectors normalizes results of every sector separately when
+// ranking is sector neutral, so companies are only compared with their peers.
+func (s *strategy) normalizeWithinSectors(results []criteriaEvaluationResult, companies []companyInfo) []criteriaEvaluationResult {
+	if !s.sectorNeutral {
+		return s.normalizeResults(results)
+	}
+
+	sectors := sectorsOf(companies)
+	indexesBySector := make(map[string][]int)
+	for i, result := range results {
+		sector := sectors[result.companySymbol]
+		indexesBySector[sector] = append(indexesBySector[sector], i)
+	}
+
+	normalizedResults := make([]criteriaEvaluationResult, len(results))
+	for _, indexes := range indexesBySector {
+		sectorResults := make([]criteriaEvaluationResult, len(indexes))
+		for j, index := range indexes {
+			sectorResults[j] = results[index]
+		}
+		for j, normalizedResult := range s.normalizeResults(sectorResults) {
+			normalizedResults[indexes[j]] = normalizedResult
+		}
+	}
+	return normalizedResults
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func companyInSector(symbol string, sector string, netIncomeGrowth float64) companyInfo {
+	return companyInfo{
+		symbol:  symbol,
+		profile: Profile{Sector: sector},
+		growth:  []FinancialGrowth{{Date: "2020-12-31", NetIncomeGrowth: netIncomeGrowth}},
+	}
+}
+
+var sectorCompanies = []companyInfo{
+	companyInSector("TECH1", "Technology", 0.5),
+	companyInSector("TECH2", "Technology", 0.4),
+	companyInSector("TECH3", "Technology", 0.3),
+	companyInSector("UTIL1", "Utilities", 0.05),
+	companyInSector("UTIL2", "Utilities", 0.02),
+	companyInSector("UNKN", "", 0.01),
+}
+
+func TestSector_limit_names_selects_next_ranked_of_other_sectors(t *testing.T) {
+	// Given
+	strategy := strategy{
+		criteria:      []criterion{{criterionType: netIncomeGrowth, period: periodAnnual, weight: 1, direction: highest}},
+		normalization: normalization{method: percentileRank},
+	}
+
+	// When
+	topCompanies, _ := strategy.evaluateTopCompanies(sectorCompanies, evaluationDate, 3, holdings{}, sectorLimits{maxNames: 1})
+
+	// Then
+	expectedSymbols := []string{"TECH1", "UTIL1", "UNKN"}
+	if !reflect.DeepEqual(selectedSymbols(topCompanies), expectedSymbols) {
+		t.Fatalf("expected one company per sector, actual: %v", selectedSymbols(topCompanies))
+	}
+	rankedSelected := make([]string, 0)
+	for _, row := range strategy.rankings[0].rows {
+		if row.selected {
+			rankedSelected = append(rankedSelected, row.symbol)
+		}
+	}
+	if !reflect.DeepEqual(rankedSelected, expectedSymbols) {
+		t.Fatalf("expected ranking to mark %v as selected, actual: %v", expectedSymbols, rankedSelected)
+	}
+}
+
+func TestSector_cap_weights_redistributes_excess(t *testing.T) {
+	// Given
+	limits := sectorLimits{maxWeight: 0.5}
+	companies := sectorCompanies[1:5]
+
+	// When
+	weights := limits.capWeights(companies, []float64{0.3, 0.3, 0.3, 0.1})
+
+	// Then
+	assertValues(t, []float64{0.25, 0.25, 0.375, 0.125}, weights)
+}
+
+func TestSector_neutral_ranking_selects_best_of_every_sector(t *testing.T) {
+	// Given
+	strategy := strategy{
+		criteria:      []criterion{{criterionType: netIncomeGrowth, period: periodAnnual, weight: 1, direction: highest}},
+		normalization: normalization{method: percentileRank},
+		sectorNeutral: true,
+	}
+
+	// When
+	topCompanies, _ := strategy.evaluateTopCompanies(sectorCompanies[:5], evaluationDate, 2, holdings{}, sectorLimits{})
+
+	// Then
+	if !reflect.DeepEqual(selectedSymbols(topCompanies), []string{"TECH1", "UTIL1"}) {
+		t.Fatalf("expected the best company of every sector, actual: %v", selectedSymbols(topCompanies))
+	}
+}
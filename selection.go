@@ -0,0 +1,69 @@
+package main
+
+import "time"
+
+// selectionRules make ranked selection more stable, so companies hovering
+// around the cutoff rank do not flip in and out of portfolio.
+type selectionRules struct {
+	// Held companies stay selected while ranked within portfolio size plus buffer.
+	buffer int
+	// Calendar days held companies stay selected after entry, regardless
+	// of rank, as long as they can be evaluated.
+	minHoldingDays int
+	// Calendar days after exit before a company can be selected again.
+	reentryCooldownDays int
+}
+
+// holdings is what selection rules need to know about portfolio.
+type holdings struct {
+	// Entry dates of held long positions.
+	entryDates map[string]time.Time
+	// Dates of the latest exit of every company ever held.
+	exitDates map[string]time.Time
+}
+
+func (h holdings) held(symbol string) bool {
+	_, held := h.entryDates[symbol]
+	return held
+}
+
+// keeps tells whether held company stays selected at given rank, starting from 1.
+func (r selectionRules) keeps(symbol string, rank int, portfolioSize int, holdings holdings, date time.Time) bool {
+	entryDate, held := holdings.entryDates[symbol]
+	if !held {
+		return false
+	}
+	return rank <= portfolioSize+r.buffer || date.Before(entryDate.AddDate(0, 0, r.minHoldingDays))
+}
+
+// coolsDown tells whether company exited too recently to be selected again.
+func (r selectionRules) coolsDown(symbol string, holdings holdings, date time.Time) bool {
+	exitDate, exited := holdings.exitDates[symbol]
+	return exited && !holdings.held(symbol) && date.Before(exitDate.AddDate(0, 0, r.reentryCooldownDays))
+}
+
+// holdings returns entry dates of held long positions and dates of all exits.
+func (p *portfolio) holdings() holdings {
+	entryDates := make(map[string]time.Time)
+	for _, position := range p.positions {
+		if entryDate, tracked := p.entryDates[position.company.symbol]; tracked && position.amountOfShares > 0 {
+			entryDates[position.company.symbol] = entryDate
+		}
+	}
+	return holdings{entryDates: entryDates, exitDates: p.exitDates}
+}
+
+func (p *portfolio) recordEntry(order signal) {
+	if p.entryDates == nil {
+		p.entryDates = make(map[string]time.Time)
+	}
+	p.entryDates[order.company.symbol] = order.date
+}
+
+func (p *portfolio) recordExit(order signal) {
+	if p.exitDates == nil {
+		p.exitDates = make(map[string]time.Time)
+	}
+	delete(p.entryDates, order.company.symbol)
+	p.exitDates[order.company.symbol] = order.date
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var rankedCompanies = []companyInfo{{symbol: "A"}, {symbol: "B"}, {symbol: "C"}, {symbol: "D"}}
+
+var rankedResults = map[string]float64{"A": 4, "B": 3, "C": 2, "D": 1}
+
+func selectedSymbols(companies []companyInfo) []string {
+	symbols := make([]string, 0, len(companies))
+	for _, company := range companies {
+		symbols = append(symbols, company.symbol)
+	}
+	return symbols
+}
+
+func TestSelection_buffer_keeps_held_company_within_rank(t *testing.T) {
+	// Given
+	strategy := strategy{selection: selectionRules{buffer: 1}}
+	holdingC := holdings{entryDates: map[string]time.Time{"C": evaluationDate.AddDate(0, -1, 0)}}
+	holdingD := holdings{entryDates: map[string]time.Time{"D": evaluationDate.AddDate(0, -1, 0)}}
+
+	// When
+	keptC := strategy.selectTopCompanies(rankedResults, rankedCompanies, 2, holdingC, sectorLimits{}, evaluationDate)
+	keptD := strategy.selectTopCompanies(rankedResults, rankedCompanies, 2, holdingD, sectorLimits{}, evaluationDate)
+
+	// Then
+	if !reflect.DeepEqual(selectedSymbols(keptC), []string{"A", "C"}) || !reflect.DeepEqual(selectedSymbols(keptD), []string{"A", "B"}) {
+		t.Fatalf("expected C to be kept within buffer and D to be replaced, actual: %v and %v", selectedSymbols(keptC), selectedSymbols(keptD))
+	}
+}
+
+func TestSelection_minimum_holding_period(t *testing.T) {
+	// Given
+	strategy := strategy{selection: selectionRules{minHoldingDays: 30}}
+	recent := holdings{entryDates: map[string]time.Time{"D": evaluationDate.AddDate(0, 0, -29)}}
+	old := holdings{entryDates: map[string]time.Time{"D": evaluationDate.AddDate(0, 0, -30)}}
+
+	// When
+	keptRecent := strategy.selectTopCompanies(rankedResults, rankedCompanies, 2, recent, sectorLimits{}, evaluationDate)
+	keptOld := strategy.selectTopCompanies(rankedResults, rankedCompanies, 2, old, sectorLimits{}, evaluationDate)
+
+	// Then
+	if !reflect.DeepEqual(selectedSymbols(keptRecent), []string{"A", "D"}) || !reflect.DeepEqual(selectedSymbols(keptOld), []string{"A", "B"}) {
+		t.Fatalf("expected D to be kept only within holding period, actual: %v and %v", selectedSymbols(keptRecent), selectedSymbols(keptOld))
+	}
+}
+
+func TestSelection_reentry_cooldown(t *testing.T) {
+	// Given
+	strategy := strategy{selection: selectionRules{reentryCooldownDays: 10}}
+	exitedA := holdings{exitDates: map[string]time.Time{"A": evaluationDate.AddDate(0, 0, -5)}}
+
+	// When
+	selected := strategy.selectTopCompanies(rankedResults, rankedCompanies, 2, exitedA, sectorLimits{}, evaluationDate)
+
+	// Then
+	if !reflect.DeepEqual(selectedSymbols(selected), []string{"B", "C"}) {
+		t.Fatalf("expected A to be skipped while cooling down, actual: %v", selectedSymbols(selected))
+	}
+}
+
+func TestSelection_portfolio_tracks_entries_and_exits(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 1, action: buy})
+	portfolio.performSignalAction(signal{date: friday, company: apple, price: 100, amountOfShares: 1, action: buy})
+
+	// When
+	portfolio.performSignalAction(signal{date: friday.AddDate(0, 0, 3), company: apple, price: 100, amountOfShares: 1, action: sell})
+
+	// Then
+	holdings := portfolio.holdings()
+	if !holdings.held("WEEK") || holdings.held(apple.symbol) || !holdings.exitDates[apple.symbol].Equal(friday.AddDate(0, 0, 3)) {
+		t.Fatalf("expected WEEK to be held and apple exited, actual holdings: %+v", holdings)
+	}
+}
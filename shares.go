@@ -0,0 +1,48 @@
+package main
+
+import "math"
+
+// shareSizing configures which amounts of shares can be traded.
+// By default only whole shares are traded.
+type shareSizing struct {
+	// Decimal places of fractional shares offered by broker,
+	// e.g. 4 allows buying 0.0001 share.
+	precision int
+	// Shares are traded in multiples of lot size, e.g. 100 on markets
+	// trading in round lots. Overrides precision when set.
+	lotSize float64
+}
+
+// step is the smallest tradable amount of shares.
+func (s shareSizing) step() float64 {
+	if s.lotSize > 0 {
+		return s.lotSize
+	}
+	return math.Pow10(-s.precision)
+}
+
+// roundDown rounds amount of shares towards zero to a tradable amount.
+func (s shareSizing) roundDown(amount float64) float64 {
+	step := s.step()
+	return s.round(math.Trunc(amount/step+shareRoundingTolerance) * step)
+}
+
+// roundUp rounds amount of shares away from zero to a tradable amount.
+func (s shareSizing) roundUp(amount float64) float64 {
+	step := s.step()
+	return s.round(math.Copysign(math.Ceil(math.Abs(amount)/step-shareRoundingTolerance), amount) * step)
+}
+
+// round removes floating point noise left by adding and subtracting
+// fractional amounts, so that selling whole position leaves exactly 0.
+func (s shareSizing) round(amount float64) float64 {
+	scale := math.Pow10(s.precision)
+	if s.lotSize > 0 {
+		scale = 1 / s.lotSize
+	}
+	return math.Round(amount*scale) / scale
+}
+
+// Tolerates floating point error of amounts which are already tradable,
+// e.g. 0.3/0.1 being 2.9999999999999996.
+const shareRoundingTolerance = 1e-9
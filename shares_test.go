@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestShares_whole_shares_by_default(t *testing.T) {
+	// Given
+	portfolio := portfolio{}
+
+	// When
+	amount, price := portfolio.calculateAmountAndPriceOfShares(weekly, 950, friday)
+
+	// Then
+	if amount != 9 || price != 100 {
+		t.Fatalf("expected 9 shares at 100, actual: %g shares at %g", amount, price)
+	}
+}
+
+func TestShares_fractional_shares_with_precision(t *testing.T) {
+	// Given
+	portfolio := portfolio{shares: shareSizing{precision: 3}}
+
+	// When
+	amount, _ := portfolio.calculateAmountAndPriceOfShares(weekly, 12.34567, friday)
+
+	// Then
+	if amount != 0.123 {
+		t.Fatalf("expected 0.123 shares, actual: %g", amount)
+	}
+}
+
+func TestShares_round_lots(t *testing.T) {
+	// Given
+	sizing := shareSizing{lotSize: 100}
+
+	// When
+	down, up := sizing.roundDown(1999), sizing.roundUp(-1001)
+
+	// Then
+	if down != 1900 || up != -1100 {
+		t.Fatalf("expected 1900 and -1100, actual: %g and %g", down, up)
+	}
+}
+
+func TestShares_selling_fractional_position_in_parts_closes_it(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 100, shares: shareSizing{precision: 4}}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 0.3, action: buy})
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 0.1, action: sell})
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 0.2, action: sell})
+
+	// Then
+	if len(portfolio.positions) != 0 {
+		t.Fatalf("expected position to be closed, actual positions: %+v", portfolio.positions)
+	}
+	assertFloat(t, 100, portfolio.capital)
+}
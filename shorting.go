@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// Long/short neutrality
+const (
+	// Short book is worth as much as the long book.
+	dollarNeutral = "DOLLAR_NEUTRAL"
+	// Short book has the same beta weighted value as the long book.
+	betaNeutral = "BETA_NEUTRAL"
+)
+
+// Day count of annual borrow fee rate, as used by US brokers.
+const borrowFeeDayCount = 360.0
+
+// shortSelling configures the short book of a long/short portfolio,
+// which shorts the companies the strategy rates worst.
+type shortSelling struct {
+	// Number of companies shorted, short selling is off when 0.
+	size int
+	// dollarNeutral or betaNeutral, otherwise short book is
+	// worth exposure times portfolio value.
+	neutrality string
+	exposure   float64
+	// Fraction of short book value which has to be covered by portfolio value.
+	marginRequirement float64
+	// Annual fee rate charged daily on value of short positions.
+	borrowFeeRate float64
+}
+
+func (p *portfolio) allowsShorting() bool {
+	return p.shortSelling.size > 0
+}
+
+var benchmarkMissing = errors.New("beta neutral short book requires benchmark")
+
+// calculateShortPositions sizes short positions in bottom companies, equally
+// weighted within the short book, limited by margin the portfolio can cover.
+func (p *portfolio) calculateShortPositions(bottomCompanies []companyInfo, longPositions []position, portfolioValue float64, date time.Time) ([]position, error) {
+	positions := make([]position, 0)
+	if len(bottomCompanies) == 0 {
+		return positions, nil
+	}
+
+	var longValue float64
+	for _, longPosition := range longPositions {
+		positionValue, err := p.toBase(longPosition.company, longPosition.atPrice*longPosition.amountOfShares, date)
+		if err != nil {
+			return nil, err
+		}
+		longValue += positionValue
+	}
+
+	var shortBookValue float64
+	switch p.shortSelling.neutrality {
+	case dollarNeutral:
+		shortBookValue = longValue
+	case betaNeutral:
+		longBeta, err := p.betaWeightedValue(longPositions, date)
+		if err != nil {
+			return nil, err
+		}
+		shortBetas := make([]float64, len(bottomCompanies))
+		var averageShortBeta float64
+		for i, company := range bottomCompanies {
+			shortBetas[i], err = beta(company, p.benchmark, date, p.weighting.lookback())
+			if err != nil {
+				return nil, err
+			}
+			averageShortBeta += shortBetas[i] / float64(len(bottomCompanies))
+		}
+		if averageShortBeta <= 0 {
+			log.Printf("cannot neutralize beta %.2f of long book with short book of beta %.2f \n", longBeta, averageShortBeta)
+			return positions, nil
+		}
+		shortBookValue = longBeta / averageShortBeta
+	default:
+		shortBookValue = portfolioValue * p.shortSelling.exposure
+	}
+	if p.shortSelling.marginRequirement > 0 {
+		shortBookValue = math.Min(shortBookValue, portfolioValue/p.shortSelling.marginRequirement)
+	}
+
+	for _, company := range bottomCompanies {
+		amountOfShares, price := p.calculateAmountAndPriceOfShares(company, shortBookValue/float64(len(bottomCompanies)), date)
+		positions = append(positions, position{
+			company:        company,
+			amountOfShares: -amountOfShares,
+			atPrice:        price,
+		})
+	}
+
+	return positions, nil
+}
+
+func (p *portfolio) betaWeightedValue(positions []position, date time.Time) (float64, error) {
+	if len(p.benchmark.historicalPrice.Historical) == 0 {
+		return 0, benchmarkMissing
+	}
+	var value float64
+	for _, position := range positions {
+		positionBeta, err := beta(position.company, p.benchmark, date, p.weighting.lookback())
+		if err != nil {
+			return 0, err
+		}
+		positionValue, err := p.toBase(position.company, position.atPrice*position.amountOfShares, date)
+		if err != nil {
+			return 0, err
+		}
+		value += positionBeta * positionValue
+	}
+	return value, nil
+}
+
+// beta of company against benchmark from daily returns over lookback days.
+func beta(company companyInfo, benchmark companyInfo, date time.Time, lookbackDays int) (float64, error) {
+	if len(benchmark.historicalPrice.Historical) == 0 {
+		return 0, benchmarkMissing
+	}
+	companyCloses, err := getClosePrices(company.historicalPrice.Historical, date, lookbackDays)
+	if err != nil {
+		return 0, err
+	}
+	benchmarkCloses, err := getClosePrices(benchmark.historicalPrice.Historical, date, lookbackDays)
+	if err != nil {
+		return 0, err
+	}
+	benchmarkReturns := dailyReturns(benchmarkCloses)
+	benchmarkVariance := Covariance(benchmarkReturns, benchmarkReturns)
+	if benchmarkVariance == 0 {
+		return 0, nil
+	}
+	return Covariance(dailyReturns(companyCloses), benchmarkReturns) / benchmarkVariance, nil
+}
+
+// accrueBorrowFees charges one day of borrow fee on value of short positions.
+func (p *portfolio) accrueBorrowFees(date time.Time) {
+	if p.shortSelling.borrowFeeRate == 0 {
+		return
+	}
+	for _, position := range p.positions {
+		if position.amountOfShares >= 0 {
+			continue
+		}
+		positionValue, err := p.positionValue(position, date)
+		if err != nil {
+			continue
+		}
+		shortValue := -positionValue
+		fee := shortValue * p.shortSelling.borrowFeeRate / borrowFeeDayCount
+		p.capital -= fee
+		p.paidBorrowFees += fee
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestShort_selling_opens_and_covering_closes_short_position(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, shortSelling: shortSelling{size: 1}}
+
+	// When
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: sell})
+	shortShares, capitalWithProceeds := portfolio.positions[0].amountOfShares, portfolio.capital
+	value, _ := portfolio.calculatePortfolioValue(friday.AddDate(0, 0, 3))
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 105, amountOfShares: 5, action: buy})
+
+	// Then
+	if shortShares != -5 || len(portfolio.positions) != 0 {
+		t.Fatalf("expected short of 5 shares to be opened and covered, actual short: %g, positions: %+v", shortShares, portfolio.positions)
+	}
+	assertFloat(t, 1500, capitalWithProceeds)
+	assertFloat(t, 1000-5*5, value)
+	assertFloat(t, 1000-5*5, portfolio.capital)
+}
+
+func TestShort_selling_rejected_when_off(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+
+	// When
+	portfolio.submitOrders([]signal{{date: friday, company: weekly, price: 100, amountOfShares: 5, action: sell}})
+
+	// Then
+	if len(portfolio.positions) != 0 || portfolio.capital != 1000 {
+		t.Fatalf("expected short sale to be rejected, actual portfolio: %+v", portfolio)
+	}
+	if portfolio.orderStats.filled != 0 {
+		t.Fatalf("expected rejected order not to count as filled, actual order stats: %+v", portfolio.orderStats)
+	}
+}
+
+func TestShort_dollar_neutral_book_limited_by_margin(t *testing.T) {
+	// Given
+	portfolio := portfolio{
+		commision:    commision{},
+		capital:      1000,
+		size:         1,
+		shortSelling: shortSelling{size: 1, neutrality: dollarNeutral, marginRequirement: 2},
+	}
+
+	// When
+	positions, err := portfolio.calculateNewPositions([]companyInfo{apple}, []companyInfo{tesla}, nil, date)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if positions[0].amountOfShares != 10 || positions[1].amountOfShares != -5 {
+		t.Fatalf("expected long of 10 and short of 5 shares, actual positions: %+v", positions)
+	}
+	signals := portfolio.generateSignals(positions, date)
+	if signals[1].action != sell || signals[1].amountOfShares != 5 {
+		t.Fatalf("expected short sale of 5 shares, actual signal: %+v", signals[1])
+	}
+}
+
+func TestShort_borrow_fees_accrue_daily(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, shortSelling: shortSelling{size: 1, borrowFeeRate: 0.036}}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 10, action: sell})
+
+	// When
+	portfolio.accrueBorrowFees(friday)
+
+	// Then
+	assertFloat(t, 0.1, portfolio.paidBorrowFees)
+	assertFloat(t, 1999.9, portfolio.capital)
+}
+
+func TestShort_stop_loss_is_above_entry(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, shortSelling: shortSelling{size: 1}, riskRules: riskRules{stopLoss: 0.05}}
+	portfolio.performSignalAction(signal{date: entryDate, company: trending, price: 100, amountOfShares: 10, action: sell})
+
+	// When
+	checkRiskRulesUntil(&portfolio, entryDate.AddDate(0, 0, 3))
+
+	// Then
+	if len(portfolio.riskExits) != 1 || portfolio.riskExits[0].action != buy {
+		t.Fatalf("expected short to be covered by stop loss, actual exits: %+v", portfolio.riskExits)
+	}
+	assertFloat(t, 105, portfolio.riskExits[0].price)
+	if portfolio.riskExits[0].date.Format(dateLayout) != "2021-01-05" {
+		t.Fatalf("expected stop loss on 2021-01-05, actual date: %s", portfolio.riskExits[0].date.Format(dateLayout))
+	}
+}
+
+func TestShort_select_bottom_companies_skips_top_ones(t *testing.T) {
+	// Given
+	strategy := strategy{}
+	scores := map[string]float64{"AAPL": 0.9, "TSLA": 0.1, "INSU": 0.5}
+	companies := []companyInfo{apple, tesla, insufficient}
+
+	// When
+	bottom := strategy.selectBottomCompanies(scores, companies, 2, []companyInfo{apple})
+
+	// Then
+	if len(bottom) != 2 || bottom[0].symbol != "TSLA" || bottom[1].symbol != "INSU" {
+		t.Fatalf("expected bottom companies TSLA and INSU, actual: %+v", bottom)
+	}
+}
+
+func TestShort_borrow_fees_on_weekend_accrue_at_last_close(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, shortSelling: shortSelling{size: 1, borrowFeeRate: 0.036}}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 10, action: sell})
+
+	// When
+	portfolio.accrueBorrowFees(friday.AddDate(0, 0, 1))
+
+	// Then
+	// Short of 10 shares is valued at Friday close of 100, not at Monday close of 105
+	assertFloat(t, 0.1, portfolio.paidBorrowFees)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// Trading days average volume and volatility are estimated
+// over by square root impact model when not configured.
+const defaultImpactLookbackDays = 21
+
+// slippageModel estimates by how much per share the fill price
+// of an order is worse than the price the order was placed at.
+type slippageModel interface {
+	estimate(order signal) float64
+}
+
+// fixedSlippage moves fill price by given basis points of the price.
+type fixedSlippage struct {
+	basisPoints float64
+}
+
+func (s fixedSlippage) estimate(order signal) float64 {
+	return order.price * s.basisPoints / 10000
+}
+
+// halfSpreadSlippage pays half of the bid-ask spread, estimated from High and Low
+// of the order day and the day before with Corwin-Schultz estimator.
+type halfSpreadSlippage struct{}
+
+func (s halfSpreadSlippage) estimate(order signal) float64 {
+	prices, err := getPrices(order.company.historicalPrice.Historical, order.date, 1)
+	if err != nil {
+		log.Printf("error while estimating spread of %s: %s \n", order.company.symbol, err)
+		return 0
+	}
+	today, yesterday := prices[0], prices[1]
+	if today.Low <= 0 || yesterday.Low <= 0 {
+		return 0
+	}
+
+	beta := math.Pow(math.Log(today.High/today.Low), 2) + math.Pow(math.Log(yesterday.High/yesterday.Low), 2)
+	gamma := math.Pow(math.Log(math.Max(today.High, yesterday.High)/math.Min(today.Low, yesterday.Low)), 2)
+	denominator := 3 - 2*math.Sqrt2
+	alpha := (math.Sqrt(2*beta)-math.Sqrt(beta))/denominator - math.Sqrt(gamma/denominator)
+	spread := 2 * (math.Exp(alpha) - 1) / (1 + math.Exp(alpha))
+	if spread < 0 {
+		return 0
+	}
+
+	return order.price * spread / 2
+}
+
+// squareRootImpact models market impact growing with square root of order
+// size relative to average daily volume, scaled by daily volatility.
+type squareRootImpact struct {
+	coefficient  float64
+	lookbackDays int
+}
+
+func (s squareRootImpact) estimate(order signal) float64 {
+	lookbackDays := s.lookbackDays
+	if lookbackDays == 0 {
+		lookbackDays = defaultImpactLookbackDays
+	}
+	prices, err := getPrices(order.company.historicalPrice.Historical, order.date, lookbackDays)
+	if err != nil {
+		log.Printf("error while estimating market impact of %s: %s \n", order.company.symbol, err)
+		return 0
+	}
+
+	closes := make([]float64, len(prices))
+	volumes := make([]float64, len(prices))
+	for i, price := range prices {
+		closes[i] = price.Close
+		volumes[i] = price.Volume
+	}
+	averageVolume := Sma(volumes[1:]...)
+	if averageVolume == 0 {
+		return 0
+	}
+	volatility := StdDev(dailyReturns(closes)...)
+
+	return order.price * s.coefficient * volatility * math.Sqrt(order.amountOfShares/averageVolume)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSlippage_fixed_basis_points(t *testing.T) {
+	// Given
+	order := signal{price: 200, amountOfShares: 10, action: buy}
+
+	// When
+	slippage := fixedSlippage{basisPoints: 5}.estimate(order)
+
+	// Then
+	assertFloat(t, 0.1, slippage)
+}
+
+func TestSlippage_half_spread_from_high_and_low(t *testing.T) {
+	// Given
+	company := companyWithCloses("SPRD", []float64{100, 100})
+	company.historicalPrice.Historical[0].High, company.historicalPrice.Historical[0].Low = 101, 99
+	company.historicalPrice.Historical[1].High, company.historicalPrice.Historical[1].Low = 101, 99
+	flat := companyWithCloses("FLAT", []float64{100, 100})
+
+	// When
+	spreadSlippage := halfSpreadSlippage{}.estimate(signal{date: weightingDate, company: company, price: 100, amountOfShares: 1})
+	flatSlippage := halfSpreadSlippage{}.estimate(signal{date: weightingDate, company: flat, price: 100, amountOfShares: 1})
+
+	// Then
+	if spreadSlippage <= 0 || spreadSlippage >= 1 {
+		t.Fatalf("expected half spread between 0 and half of daily range, actual: %f", spreadSlippage)
+	}
+	assertFloat(t, 0, flatSlippage)
+}
+
+func TestSlippage_square_root_impact_grows_with_square_root_of_size(t *testing.T) {
+	// Given
+	company := companyWithCloses("IMPC", alternatingCloses(22, 0.02))
+	for i := range company.historicalPrice.Historical {
+		company.historicalPrice.Historical[i].Volume = 10_000
+	}
+	model := squareRootImpact{coefficient: 1}
+
+	// When
+	small := model.estimate(signal{date: weightingDate, company: company, price: 100, amountOfShares: 100})
+	large := model.estimate(signal{date: weightingDate, company: company, price: 100, amountOfShares: 400})
+
+	// Then
+	if small <= 0 {
+		t.Fatalf("expected positive market impact, actual: %f", small)
+	}
+	assertFloat(t, 2*small, large)
+}
+
+func TestSlippage_worsens_fill_price_and_is_reported(t *testing.T) {
+	// Given
+	portfolio := portfolio{slippage: fixedSlippage{basisPoints: 10}}
+	buyOrder := signal{price: 100, amountOfShares: 10, action: buy}
+	sellOrder := signal{price: 100, amountOfShares: 10, action: sell}
+
+	// When
+	filledBuy := portfolio.applySlippage(buyOrder)
+	filledSell := portfolio.applySlippage(sellOrder)
+
+	// Then
+	assertFloat(t, 100.1, filledBuy.price)
+	assertFloat(t, 99.9, filledSell.price)
+	assertFloat(t, 2, portfolio.paidSlippage)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// snapshot is the state of portfolio right after a rebalance, once its orders
+// have been filled or cancelled. It holds copies of values, so later trades
+// do not change it. Amounts are in base currency.
+type snapshot struct {
+	// Date state was valued on, the rebalance date unless orders filled later.
+	Date          time.Time          `json:"date"`
+	RebalanceDate time.Time          `json:"rebalanceDate"`
+	Cash          float64            `json:"cash"`
+	Value         float64            `json:"value"`
+	Positions     []positionSnapshot `json:"positions"`
+}
+
+type positionSnapshot struct {
+	Symbol string `json:"symbol"`
+	Sector string `json:"sector,omitempty"`
+	// Negative for short positions.
+	Shares float64 `json:"shares"`
+	// Close price in instrument currency.
+	Price float64 `json:"price"`
+	// Cost basis of open tax lots, proceeds for short positions.
+	Cost           float64 `json:"cost"`
+	MarketValue    float64 `json:"marketValue"`
+	Weight         float64 `json:"weight"`
+	UnrealizedGain float64 `json:"unrealizedGain"`
+}
+
+// takeSnapshot records state of portfolio valued at closes on date or, on days
+// without trading, before it. Positions which cannot be valued are left out.
+func (p *portfolio) takeSnapshot(rebalanceDate time.Time, date time.Time) snapshot {
+	value, err := p.calculatePortfolioValue(date)
+	if err != nil {
+		value, _ = p.calculatePortfolioValueAtOrBefore(date)
+	}
+
+	positions := make([]positionSnapshot, 0, len(p.positions))
+	for _, position := range p.positions {
+		price, err := closePriceAtOrBefore(position.company, date)
+		if err != nil {
+			continue
+		}
+		marketValue, err := p.positionValue(position, date)
+		if err != nil {
+			continue
+		}
+		var cost float64
+		for _, lot := range heldLots(position) {
+			cost += lot.shares * lot.price
+		}
+		weight := 0.0
+		if value != 0 {
+			weight = marketValue / value
+		}
+		positions = append(positions, positionSnapshot{
+			Symbol:         position.company.symbol,
+			Sector:         position.company.profile.Sector,
+			Shares:         position.amountOfShares,
+			Price:          price,
+			Cost:           cost,
+			MarketValue:    marketValue,
+			Weight:         weight,
+			UnrealizedGain: marketValue - cost,
+		})
+	}
+
+	snapshot := snapshot{Date: date, RebalanceDate: rebalanceDate, Cash: p.capital, Value: value, Positions: positions}
+	p.snapshots = append(p.snapshots, snapshot)
+	return snapshot
+}
+
+// snapshotRebalance takes snapshot of rebalance on date right away
+// if its orders filled at once, otherwise once they are no longer pending.
+func (p *portfolio) snapshotRebalance(date time.Time) {
+	p.snapshotPendingSince = date
+	p.takePendingSnapshot(date, false)
+}
+
+// takePendingSnapshot takes snapshot of the last rebalance on date, once its
+// orders have been filled or cancelled, or regardless of them when forced,
+// e.g. before they are cancelled by the next rebalance or at the end of backtest.
+func (p *portfolio) takePendingSnapshot(date time.Time, force bool) {
+	if p.snapshotPendingSince.IsZero() || len(p.pendingOrders) > 0 && !force {
+		return
+	}
+	p.takeSnapshot(p.snapshotPendingSince, date)
+	p.snapshotPendingSince = time.Time{}
+}
+
+func saveSnapshots(w io.Writer, snapshots []snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshots)
+}
+
+func loadSnapshots(r io.Reader) ([]snapshot, error) {
+	var snapshots []snapshot
+	if err := json.NewDecoder(r).Decode(&snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
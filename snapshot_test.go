@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot_captures_positions_and_is_not_changed_by_later_trades(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 90, amountOfShares: 5, action: buy})
+
+	// When
+	snapshot := portfolio.takeSnapshot(friday, friday)
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy})
+
+	// Then
+	expected := positionSnapshot{Symbol: "WEEK", Shares: 5, Price: 100, Cost: 450, MarketValue: 500, Weight: 500.0 / 1050, UnrealizedGain: 50}
+	if snapshot.Cash != 550 || snapshot.Value != 1050 || !reflect.DeepEqual(snapshot.Positions, []positionSnapshot{expected}) {
+		t.Fatalf("expected snapshot of 550 cash and position %+v, actual snapshot: %+v", expected, snapshot)
+	}
+}
+
+func TestSnapshot_save_and_load_json(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy})
+	portfolio.takeSnapshot(friday, friday)
+	var saved bytes.Buffer
+
+	// When
+	err := saveSnapshots(&saved, portfolio.snapshots)
+	loaded, loadErr := loadSnapshots(&saved)
+
+	// Then
+	if err != nil || loadErr != nil || !reflect.DeepEqual(loaded, portfolio.snapshots) {
+		t.Fatalf("expected loaded snapshots to equal saved ones, actual: %+v, errors: %v, %v", loaded, err, loadErr)
+	}
+}
+
+func TestSnapshot_on_weekend_is_valued_at_last_close(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000}
+	portfolio.performSignalAction(signal{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy})
+
+	// When
+	snapshot := portfolio.takeSnapshot(friday.AddDate(0, 0, 1), friday.AddDate(0, 0, 1))
+
+	// Then
+	// Friday close of 100, not Monday close of 105
+	if snapshot.Value != 1000 || snapshot.Positions[0].Price != 100 || snapshot.Positions[0].MarketValue != 500 {
+		t.Fatalf("expected snapshot valued at Friday close, actual snapshot: %+v", snapshot)
+	}
+}
+
+func TestSnapshot_waits_for_orders_of_rebalance_to_fill(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{}, capital: 1000, execution: nextDayOpen}
+	monday := friday.AddDate(0, 0, 3)
+
+	// When
+	portfolio.submitOrders([]signal{{date: friday, company: weekly, price: 100, amountOfShares: 5, action: buy}})
+	portfolio.snapshotRebalance(friday)
+	snapshotsBeforeFill := len(portfolio.snapshots)
+	for date := friday.AddDate(0, 0, 1); !date.After(monday); date = date.AddDate(0, 0, 1) {
+		portfolio.fillPendingOrders(date)
+		portfolio.takePendingSnapshot(date, false)
+	}
+
+	// Then
+	// 5 shares bought at Monday open of 102 and valued at Monday close of 105
+	if snapshotsBeforeFill != 0 || len(portfolio.snapshots) != 1 {
+		t.Fatalf("expected single snapshot once order filled, actual snapshots before fill: %d, after: %+v", snapshotsBeforeFill, portfolio.snapshots)
+	}
+	snapshot := portfolio.snapshots[0]
+	if !snapshot.Date.Equal(monday) || !snapshot.RebalanceDate.Equal(friday) || len(snapshot.Positions) != 1 || snapshot.Value != 1015 {
+		t.Fatalf("expected snapshot of Friday rebalance on Monday worth 1015, actual snapshot: %+v", snapshot)
+	}
+}
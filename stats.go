@@ -0,0 +1,33 @@
+package main
+
+import "math"
+
+func StdDev(numbers ...float64) float64 {
+	if len(numbers) < 2 {
+		return 0
+	}
+	mean := Sma(numbers...)
+
+	var sumOfSquares float64
+	for _, number := range numbers {
+		sumOfSquares += (number - mean) * (number - mean)
+	}
+
+	return math.Sqrt(sumOfSquares / float64(len(numbers)-1))
+}
+
+// Covariance returns sample covariance of two equally long series.
+func Covariance(x []float64, y []float64) float64 {
+	if len(x) < 2 || len(x) != len(y) {
+		return 0
+	}
+	meanX := Sma(x...)
+	meanY := Sma(y...)
+
+	var sum float64
+	for i := range x {
+		sum += (x[i] - meanX) * (y[i] - meanY)
+	}
+
+	return sum / float64(len(x)-1)
+}
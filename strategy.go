@@ -6,10 +6,17 @@ import (
 	"math"
 	"sort"
 	"time"
+
+	"backtester/factor"
 )
 
 type strategy struct {
-	criteria []criterion
+	criteria      []criterion
+	normalization normalization
+	selection     selectionRules
+	// Companies are ranked against companies of the same sector.
+	sectorNeutral bool
+	rankings      []ranking
 }
 
 type criterion struct {
@@ -17,6 +24,7 @@ type criterion struct {
 	period        string
 	weight        float64
 	direction     string
+	normalization normalization
 }
 
 type criteriaEvaluationResult struct {
@@ -26,111 +34,129 @@ type criteriaEvaluationResult struct {
 }
 
 const (
-	// Criteria
-	revenueGrowth     = "REVENUE_GROWTH"
-	grossProfitGrowth = "GROSS_PROFIT_GROWTH"
-
 	// Direction
 	lowest  = "LOWEST"
 	highest = "HIGHEST"
 )
 
-func (s *strategy) evaluateTopCompanies(companies []companyInfo, date time.Time, portfolioSize int) []companyInfo {
+// evaluateTopCompanies returns the best companies along with
+// final results of all companies which could be evaluated.
+func (s *strategy) evaluateTopCompanies(companies []companyInfo, date time.Time, portfolioSize int, holdings holdings, sectorLimits sectorLimits) ([]companyInfo, map[string]float64) {
 	evaluationResult := s.evaluateCriteria(companies, date)
-	evaluationResult = filterOutErrorResults(evaluationResult)
-	evaluationResult = s.normalizeResultsTo01(evaluationResult)
-	finalResults := s.calculateFinalResults(evaluationResult)
-	return s.selectTopCompanies(finalResults, companies, portfolioSize)
+	evaluationResult, droppedResults := filterOutErrorResults(evaluationResult)
+	normalizedResult := s.normalizeWithinSectors(evaluationResult, companies)
+	finalResults := s.calculateFinalResults(normalizedResult)
+	topCompanies := s.selectTopCompanies(finalResults, companies, portfolioSize, holdings, sectorLimits, date)
+	s.rankings = append(s.rankings, newRanking(date, evaluationResult, normalizedResult, finalResults, topCompanies, droppedResults))
+	return topCompanies, finalResults
 }
 
-var criteriaUnknown = errors.New("unknown criteria for company evaluation were provided")
-
 func (s *strategy) evaluateCriteria(companies []companyInfo, date time.Time) []criteriaEvaluationResult {
 	criteriaEvaluationResults := make([]criteriaEvaluationResult, len(companies))
 
 	for i, company := range companies {
 		results := make([]float64, len(s.criteria))
+		var evaluationError error
 
 		for j, criterion := range s.criteria {
-			switch criterion.criterionType {
-			case revenueGrowth:
-				result, err := getRevenueGrowth(company, criterion.period, date)
-				if err != nil {
-					criteriaEvaluationResults[i] = criteriaEvaluationResult{error: err}
-					break
-				}
-				results[j] = result
-			case grossProfitGrowth:
-				result, err := getGrossProfitGrowth(company, criterion.period, date)
-				if err != nil {
-					criteriaEvaluationResults[i] = criteriaEvaluationResult{error: err}
-					break
-				}
-				results[j] = result
-			default:
-				criteriaEvaluationResults[i] = criteriaEvaluationResult{error: criteriaUnknown}
+			result, err := evaluateCriterion(company, criterion, date)
+			if err != nil {
+				evaluationError = err
+				break
 			}
+			results[j] = result
 		}
 
 		criteriaEvaluationResults[i] = criteriaEvaluationResult{
 			companySymbol: company.symbol,
 			results:       results,
+			error:         evaluationError,
 		}
 	}
 
 	return criteriaEvaluationResults
 }
 
-func filterOutErrorResults(results []criteriaEvaluationResult) []criteriaEvaluationResult {
+func evaluateCriterion(company companyInfo, criterion criterion, date time.Time) (float64, error) {
+	registeredCriterion, err := factor.Lookup(criterion.criterionType)
+	if err != nil {
+		return 0.0, err
+	}
+	return registeredCriterion.Evaluate(company.view(), criterion.period, date)
+}
+
+// lookbackInDays returns the number of trading days of price history
+// price based criteria need before the first backtest date.
+func (s *strategy) lookbackInDays() int {
+	lookback := 0
+	for _, criterion := range s.criteria {
+		registeredCriterion, err := factor.Lookup(criterion.criterionType)
+		if err != nil {
+			continue
+		}
+		priceCriterion, ok := registeredCriterion.(factor.LookbackCriterion)
+		if !ok {
+			continue
+		}
+		if days := priceCriterion.LookbackInDays(criterion.period); days > lookback {
+			lookback = days
+		}
+	}
+
+	return lookback
+}
+
+// filterOutErrorResults separates results of companies which could not
+// be evaluated, returning them as the second value.
+func filterOutErrorResults(results []criteriaEvaluationResult) ([]criteriaEvaluationResult, []criteriaEvaluationResult) {
 	filtered := make([]criteriaEvaluationResult, 0)
+	dropped := make([]criteriaEvaluationResult, 0)
 
 	for _, result := range results {
 		if result.error == nil {
 			filtered = append(filtered, result)
+		} else {
+			dropped = append(dropped, result)
 		}
 	}
 
-	return filtered
+	return filtered, dropped
 }
 
-func (s *strategy) normalizeResultsTo01(companyResults []criteriaEvaluationResult) []criteriaEvaluationResult {
+// normalizeResults scales results of every criterion across companies
+// using normalization of the criterion, or of the strategy if criterion has none.
+func (s *strategy) normalizeResults(companyResults []criteriaEvaluationResult) []criteriaEvaluationResult {
+	normalizedResults := make([]criteriaEvaluationResult, len(companyResults))
+	for i, companyResult := range companyResults {
+		normalizedResults[i] = criteriaEvaluationResult{
+			companySymbol: companyResult.companySymbol,
+			results:       make([]float64, len(companyResult.results)),
+		}
+	}
 	if len(companyResults) == 0 {
-		return make([]criteriaEvaluationResult, 0)
+		return normalizedResults
 	}
 
-	minResultsOfGivenCriteria := make([]float64, 0)
-	maxResultsOfGivenCriteria := make([]float64, 0)
-
-	for _, companyResult := range companyResults {
-		for i, resultOfGivenCriterion := range companyResult.results {
-			if len(minResultsOfGivenCriteria) == i {
-				minResultsOfGivenCriteria = append(minResultsOfGivenCriteria, resultOfGivenCriterion)
-			} else if resultOfGivenCriterion < minResultsOfGivenCriteria[i] {
-				minResultsOfGivenCriteria[i] = resultOfGivenCriterion
-			}
-			if len(maxResultsOfGivenCriteria) == i {
-				maxResultsOfGivenCriteria = append(maxResultsOfGivenCriteria, resultOfGivenCriterion)
-			} else if resultOfGivenCriterion > maxResultsOfGivenCriteria[i] {
-				maxResultsOfGivenCriteria[i] = resultOfGivenCriterion
-			}
+	for j, criterion := range s.criteria {
+		resultsOfGivenCriterion := make([]float64, len(companyResults))
+		for i, companyResult := range companyResults {
+			resultsOfGivenCriterion[i] = companyResult.results[j]
 		}
-	}
 
-	for i, companyResult := range companyResults {
-		for j, criterionResult := range companyResult.results {
-			normalizedValue, err := normalizeValueTo01(
-				criterionResult,
-				minResultsOfGivenCriteria[j],
-				maxResultsOfGivenCriteria[j],
-				s.criteria[j].direction)
-			if err != nil {
-				companyResults[i].results[j] = 0
-			}
-			companyResults[i].results[j] = normalizedValue
+		normalization := s.normalization
+		if criterion.normalization.method != "" {
+			normalization = criterion.normalization
+		}
+		normalizedValues, err := normalization.normalize(resultsOfGivenCriterion, criterion.direction)
+		if err != nil {
+			continue
+		}
+		for i, normalizedValue := range normalizedValues {
+			normalizedResults[i].results[j] = normalizedValue
 		}
 	}
 
-	return companyResults
+	return normalizedResults
 }
 
 func (s *strategy) calculateFinalResults(results []criteriaEvaluationResult) map[string]float64 {
@@ -148,18 +174,37 @@ func (s *strategy) calculateFinalResults(results []criteriaEvaluationResult) map
 	return finalEvaluationResults
 }
 
-func (s *strategy) selectTopCompanies(result map[string]float64, companies []companyInfo, portfSize int) []companyInfo {
-	symbols := make([]string, 0, len(result))
-	for symbol := range result {
-		symbols = append(symbols, symbol)
-	}
-
-	sort.Slice(symbols, func(i, j int) bool {
-		return result[symbols[i]] > result[symbols[j]]
-	})
+// selectTopCompanies picks companies of the best final results. Held companies
+// kept by selection rules are picked first, companies cooling down are skipped
+// and so are companies of sectors which reached their limit of names.
+func (s *strategy) selectTopCompanies(result map[string]float64, companies []companyInfo, portfSize int, holdings holdings, sectorLimits sectorLimits, date time.Time) []companyInfo {
+	symbols := rankSymbols(result)
+	sectors := sectorsOf(companies)
 
 	amount := int(math.Min(float64(portfSize), float64(len(result))))
-	topCompaniesSymbols := symbols[0:amount]
+	selected := make(map[string]bool, amount)
+	namesPerSector := make(map[string]int)
+	for i, symbol := range symbols {
+		if len(selected) < amount && s.selection.keeps(symbol, i+1, portfSize, holdings, date) &&
+			sectorLimits.admits(sectors[symbol], namesPerSector) {
+			selected[symbol] = true
+			namesPerSector[sectors[symbol]]++
+		}
+	}
+	for _, symbol := range symbols {
+		if len(selected) < amount && !selected[symbol] && !s.selection.coolsDown(symbol, holdings, date) &&
+			sectorLimits.admits(sectors[symbol], namesPerSector) {
+			selected[symbol] = true
+			namesPerSector[sectors[symbol]]++
+		}
+	}
+
+	topCompaniesSymbols := make([]string, 0, amount)
+	for _, symbol := range symbols {
+		if selected[symbol] {
+			topCompaniesSymbols = append(topCompaniesSymbols, symbol)
+		}
+	}
 
 	topCompanies := make([]companyInfo, 0)
 
@@ -175,65 +220,72 @@ func (s *strategy) selectTopCompanies(result map[string]float64, companies []com
 	return topCompanies
 }
 
-var companyNotFound = errors.New("could not find company by symbol")
+// rankSymbols orders symbols from the best final result to the worst.
+// Ties are broken alphabetically, so ranking does not depend on map order.
+func rankSymbols(result map[string]float64) []string {
+	symbols := make([]string, 0, len(result))
+	for symbol := range result {
+		symbols = append(symbols, symbol)
+	}
 
-func findBySymbol(companies []companyInfo, symbol string) (companyInfo, error) {
-	for _, company := range companies {
-		if company.symbol == symbol {
-			return company, nil
+	sort.Slice(symbols, func(i, j int) bool {
+		if result[symbols[i]] == result[symbols[j]] {
+			return symbols[i] < symbols[j]
 		}
-	}
-	log.Println(companyNotFound, " "+symbol)
-	return companyInfo{}, companyNotFound
-}
+		return result[symbols[i]] > result[symbols[j]]
+	})
 
-func getRevenueGrowth(company companyInfo, period string, date time.Time) (float64, error) {
-	growthReport, err := getGrowthReport(company, period, date)
-	if err != nil {
-		return 0.0, err
-	}
-	return growthReport.RevenueGrowth, nil
+	return symbols
 }
 
-func getGrossProfitGrowth(company companyInfo, period string, date time.Time) (float64, error) {
-	growthReport, err := getGrowthReport(company, period, date)
-	if err != nil {
-		return 0.0, err
-	}
-	return growthReport.GrossProfitGrowth, nil
+// evaluateBottomCompanies returns companies of the worst final results
+// which are not among top companies, to be shorted.
+func (s *strategy) evaluateBottomCompanies(companies []companyInfo, date time.Time, amount int, topCompanies []companyInfo) []companyInfo {
+	evaluationResult, _ := filterOutErrorResults(s.evaluateCriteria(companies, date))
+	finalResults := s.calculateFinalResults(s.normalizeWithinSectors(evaluationResult, companies))
+	return s.selectBottomCompanies(finalResults, companies, amount, topCompanies)
 }
 
-var periodNotSupported = errors.New("period not supported. Supported periods are: periodAnnual")
-var companyGrowthNotFound = errors.New("could not find company growth report for given Date")
+// selectBottomCompanies picks companies of the worst final results, skipping
+// the ones selected as top, and marks them as shorted in the latest ranking.
+func (s *strategy) selectBottomCompanies(result map[string]float64, companies []companyInfo, amount int, topCompanies []companyInfo) []companyInfo {
+	bottomCompanies := make([]companyInfo, 0)
+	symbols := rankSymbols(result)
 
-func getGrowthReport(company companyInfo, period string, date time.Time) (FinancialGrowth, error) {
-	if period != periodAnnual {
-		return FinancialGrowth{}, periodNotSupported
-	}
-	for _, growthReport := range company.growth {
-		reportDate, err := time.Parse(dateLayout, growthReport.Date)
+	for i := len(symbols) - 1; i >= 0 && len(bottomCompanies) < amount; i-- {
+		if containsCompany(topCompanies, symbols[i]) {
+			continue
+		}
+		bottomCompany, err := findBySymbol(companies, symbols[i])
 		if err != nil {
-			return FinancialGrowth{}, timeParseError
+			continue
 		}
-		reportDatePlus1Year := reportDate.AddDate(1, 0, 0)
-
-		if reportDate.Before(date) && reportDatePlus1Year.After(date) {
-			return growthReport, nil
+		bottomCompanies = append(bottomCompanies, bottomCompany)
+		if len(s.rankings) > 0 {
+			s.rankings[len(s.rankings)-1].markShorted(symbols[i])
 		}
 	}
 
-	return FinancialGrowth{}, companyGrowthNotFound
+	return bottomCompanies
+}
+
+func containsCompany(companies []companyInfo, symbol string) bool {
+	for _, company := range companies {
+		if company.symbol == symbol {
+			return true
+		}
+	}
+	return false
 }
 
-var unsupportedDirection = errors.New("unknown direction type")
+var companyNotFound = errors.New("could not find company by symbol")
 
-func normalizeValueTo01(val float64, min float64, max float64, direction string) (float64, error) {
-	normalizedTo01 := (val - min) / (max - min)
-	if direction == highest {
-		return normalizedTo01, nil
-	} else if direction == lowest {
-		return 1 - normalizedTo01, nil
+func findBySymbol(companies []companyInfo, symbol string) (companyInfo, error) {
+	for _, company := range companies {
+		if company.symbol == symbol {
+			return company, nil
+		}
 	}
-	log.Println(unsupportedDirection, " "+direction)
-	return 0, unsupportedDirection
+	log.Println(companyNotFound, " "+symbol)
+	return companyInfo{}, companyNotFound
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"backtester/factor"
+)
+
+// ################# Criteria evaluation tests #################
+
+var evaluationDate, _ = time.Parse(dateLayout, "2021-01-20")
+
+var growing = companyInfo{
+	symbol: "GROW",
+	growth: []FinancialGrowth{{
+		Date:               "2020-12-31",
+		NetIncomeGrowth:    0.3,
+		EpsGrowth:          0.25,
+		FreeCashFlowGrowth: 0.1,
+	}},
+	ratios: []FinancialRatio{{
+		Date:               "2020-12-31",
+		PriceEarningsRatio: 35,
+		ReturnOnEquity:     0.2,
+	}},
+}
+
+var withoutReports = companyInfo{symbol: "NONE"}
+
+func TestEvaluate_growth_and_ratio_criteria(t *testing.T) {
+	// Given
+	strategy := strategy{criteria: []criterion{
+		{criterionType: netIncomeGrowth, period: periodAnnual},
+		{criterionType: epsGrowth, period: periodAnnual},
+		{criterionType: freeCashFlowGrowth, period: periodAnnual},
+		{criterionType: priceEarnings, period: PeriodQuarter},
+		{criterionType: returnOnEquity, period: PeriodQuarter},
+	}}
+	expectedResults := []float64{0.3, 0.25, 0.1, 35, 0.2}
+
+	// When
+	results := strategy.evaluateCriteria([]companyInfo{growing}, evaluationDate)
+
+	// Then
+	if results[0].error != nil {
+		t.Fatalf("unexpected error: %s", results[0].error)
+	}
+	for i, expected := range expectedResults {
+		if results[0].results[i] != expected {
+			t.Fatalf("criterion %d: expected result: %f, actual result: %f", i, expected, results[0].results[i])
+		}
+	}
+}
+
+func TestEvaluate_criteria_reports_missing_data_as_error(t *testing.T) {
+	// Given
+	strategy := strategy{criteria: []criterion{{criterionType: revenueGrowth, period: periodAnnual}}}
+
+	// When
+	results, _ := filterOutErrorResults(strategy.evaluateCriteria([]companyInfo{growing, withoutReports}, evaluationDate))
+
+	// Then
+	if len(results) != 1 || results[0].companySymbol != growing.symbol {
+		t.Fatalf("expected only %s to be evaluated, actual results: %+v", growing.symbol, results)
+	}
+}
+
+func TestEvaluate_price_earnings_excludes_companies_with_losses(t *testing.T) {
+	// Given
+	lossMaking := companyInfo{
+		symbol: "LOSS",
+		ratios: []FinancialRatio{{Date: "2020-12-31", PriceEarningsRatio: -4}},
+	}
+	strategy := strategy{criteria: []criterion{{criterionType: priceEarnings, period: PeriodQuarter}}}
+
+	// When
+	results, _ := filterOutErrorResults(strategy.evaluateCriteria([]companyInfo{growing, lossMaking}, evaluationDate))
+
+	// Then
+	if len(results) != 1 || results[0].companySymbol != growing.symbol {
+		t.Fatalf("expected only %s to be evaluated, actual results: %+v", growing.symbol, results)
+	}
+}
+
+func TestEvaluate_momentum_and_volatility(t *testing.T) {
+	// Given
+	historical := make([]Price, 0, tradingDaysInQuarter+1)
+	day := evaluationDate
+	for i := 0; i <= tradingDaysInQuarter; i++ {
+		close := 100.0
+		if i%2 == 1 {
+			close = 80.0
+		}
+		historical = append(historical, Price{Date: day.Format(dateLayout), Close: close})
+		day = day.AddDate(0, 0, -1)
+	}
+	company := companyInfo{symbol: "SWNG", historicalPrice: HistoricalPrice{Historical: historical}}
+
+	// When
+	momentumResult, momentumErr := getMomentum(company, PeriodQuarter, evaluationDate)
+	volatilityResult, volatilityErr := getVolatility(company, PeriodQuarter, evaluationDate)
+
+	// Then
+	if momentumErr != nil || volatilityErr != nil {
+		t.Fatalf("unexpected errors: %s, %s", momentumErr, volatilityErr)
+	}
+	if math.Abs(momentumResult-0.25) > 1e-9 {
+		t.Fatalf("expected momentum: %f, actual momentum: %f", 0.25, momentumResult)
+	}
+	if volatilityResult <= 0 {
+		t.Fatalf("expected positive volatility, actual volatility: %f", volatilityResult)
+	}
+}
+
+// ################# Criteria registry tests #################
+
+type symbolLengthCriterion struct{}
+
+func (c symbolLengthCriterion) Name() string {
+	return "TEST_SYMBOL_LENGTH"
+}
+
+func (c symbolLengthCriterion) Evaluate(company factor.Company, _ string, _ time.Time) (float64, error) {
+	return float64(len(company.Symbol)), nil
+}
+
+func TestEvaluate_custom_registered_criterion(t *testing.T) {
+	// Given
+	if err := factor.Register(symbolLengthCriterion{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { factor.Unregister(symbolLengthCriterion{}.Name()) })
+	strategy := strategy{criteria: []criterion{{criterionType: symbolLengthCriterion{}.Name()}}}
+
+	// When
+	results := strategy.evaluateCriteria([]companyInfo{growing}, evaluationDate)
+
+	// Then
+	if results[0].error != nil || results[0].results[0] != 4 {
+		t.Fatalf("expected result: %f, actual result: %+v", 4.0, results[0])
+	}
+}
+
+func TestRegister_criterion_twice_fails(t *testing.T) {
+	// When
+	err := factor.Register(criterionFunc{name: revenueGrowth})
+
+	// Then
+	if err != factor.CriterionAlreadyRegistered {
+		t.Fatalf("expected error: %s, actual error: %v", factor.CriterionAlreadyRegistered, err)
+	}
+}
+
+func TestEvaluate_unknown_criterion_fails(t *testing.T) {
+	// Given
+	strategy := strategy{criteria: []criterion{{criterionType: "UNKNOWN"}}}
+
+	// When
+	results := strategy.evaluateCriteria([]companyInfo{growing}, evaluationDate)
+
+	// Then
+	if results[0].error != factor.CriteriaUnknown {
+		t.Fatalf("expected error: %s, actual error: %v", factor.CriteriaUnknown, results[0].error)
+	}
+}
+
+// ################# Ranking tests #################
+
+func TestEvaluate_top_companies_records_ranking_with_dropped_companies(t *testing.T) {
+	// Given
+	shrinking := companyInfo{
+		symbol: "SHRK",
+		growth: []FinancialGrowth{{Date: "2020-12-31", NetIncomeGrowth: -0.1}},
+	}
+	strategy := strategy{criteria: []criterion{
+		{criterionType: netIncomeGrowth, period: periodAnnual, weight: 1, direction: highest},
+	}}
+
+	// When
+	topCompanies, _ := strategy.evaluateTopCompanies([]companyInfo{shrinking, withoutReports, growing}, evaluationDate, 1, holdings{}, sectorLimits{})
+
+	// Then
+	if len(topCompanies) != 1 || topCompanies[0].symbol != growing.symbol {
+		t.Fatalf("expected top company: %s, actual top companies: %+v", growing.symbol, topCompanies)
+	}
+	if len(strategy.rankings) != 1 {
+		t.Fatalf("expected 1 ranking, actual rankings: %d", len(strategy.rankings))
+	}
+	rows := strategy.rankings[0].rows
+	expectedRows := []rankingRow{
+		{symbol: "GROW", rawResults: []float64{0.3}, normalizedResults: []float64{1}, finalResult: 1, rank: 1, selected: true},
+		{symbol: "SHRK", rawResults: []float64{-0.1}, normalizedResults: []float64{0}, finalResult: 0, rank: 2},
+		{symbol: "NONE", droppedReason: companyGrowthNotFound.Error()},
+	}
+	if !reflect.DeepEqual(expectedRows, rows) {
+		t.Fatalf("expected rows: %+v, actual rows: %+v", expectedRows, rows)
+	}
+}
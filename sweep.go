@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweep backtests every combination of parameter values in parallel,
+// all of them sharing one dataset loaded up front.
+type sweep struct {
+	// Creates configuration every combination changes. It is called for every
+	// backtest, so backtests running in parallel do not share any state.
+	newBacktest func() Backtest
+	// Values of parameters, value of newBacktest is kept when empty.
+	periodsInDays   []int
+	criteriaWeights [][]float64
+	portfolioSizes  []int
+	// Days between rebalances, at least one is required.
+	iterateForDays []int
+	// Backtests run at once, number of CPUs when 0.
+	parallelism int
+}
+
+// sweepParameters is a single combination, zero values keep value of configuration.
+type sweepParameters struct {
+	periodInDays    int
+	criteriaWeights []float64
+	portfolioSize   int
+	iterateForDays  int
+}
+
+type sweepResult struct {
+	parameters sweepParameters
+	result     backtestResult
+}
+
+// sweepTable holds result of every combination, it can be sorted by any metric.
+type sweepTable []sweepResult
+
+var rebalancePeriodMissing = errors.New("sweep requires at least one rebalance period")
+
+var criteriaWeightsMismatch = errors.New("criteria weights do not match criteria of strategy")
+
+func (p sweepParameters) apply(backtest *Backtest) error {
+	if p.periodInDays > 0 {
+		backtest.screener.periodInDays = p.periodInDays
+	}
+	if p.criteriaWeights != nil {
+		if len(p.criteriaWeights) != len(backtest.strategy.criteria) {
+			return criteriaWeightsMismatch
+		}
+		criteria := make([]criterion, len(backtest.strategy.criteria))
+		copy(criteria, backtest.strategy.criteria)
+		for i := range criteria {
+			criteria[i].weight = p.criteriaWeights[i]
+		}
+		backtest.strategy.criteria = criteria
+	}
+	if p.portfolioSize > 0 {
+		backtest.portfolio.size = p.portfolioSize
+	}
+	return nil
+}
+
+// combinations returns every combination of parameter values.
+func (s sweep) combinations() []sweepParameters {
+	periods := s.periodsInDays
+	if len(periods) == 0 {
+		periods = []int{0}
+	}
+	weights := s.criteriaWeights
+	if len(weights) == 0 {
+		weights = [][]float64{nil}
+	}
+	sizes := s.portfolioSizes
+	if len(sizes) == 0 {
+		sizes = []int{0}
+	}
+
+	combinations := make([]sweepParameters, 0)
+	for _, period := range periods {
+		for _, weight := range weights {
+			for _, size := range sizes {
+				for _, iterateForDays := range s.iterateForDays {
+					combinations = append(combinations, sweepParameters{period, weight, size, iterateForDays})
+				}
+			}
+		}
+	}
+	return combinations
+}
+
+// configure creates backtest of every combination.
+func (s sweep) configure(combinations []sweepParameters) ([]Backtest, error) {
+	backtests := make([]Backtest, len(combinations))
+	for i, parameters := range combinations {
+		backtests[i] = s.newBacktest()
+		if err := parameters.apply(&backtests[i]); err != nil {
+			return nil, err
+		}
+	}
+	return backtests, nil
+}
+
+// run loads data and backtests every combination on it.
+func (s sweep) run(symbols []string, from time.Time, to time.Time) (sweepTable, error) {
+	combinations := s.combinations()
+	data, err := s.loadData(symbols, from, to, combinations)
+	if err != nil {
+		return nil, err
+	}
+	backtests, err := s.configure(combinations)
+	if err != nil {
+		return nil, err
+	}
+	return s.runOn(data, combinations, backtests, from, to), nil
+}
+
+// loadData loads data with lookback long enough for every combination.
+func (s sweep) loadData(symbols []string, from time.Time, to time.Time, combinations []sweepParameters) (dataset, error) {
+	if len(s.iterateForDays) == 0 {
+		return dataset{}, rebalancePeriodMissing
+	}
+	backtests, err := s.configure(combinations)
+	if err != nil {
+		return dataset{}, err
+	}
+
+	lookbackPeriod := 0
+	for i := range backtests {
+		if period := backtests[i].lookbackPeriod(); period > lookbackPeriod {
+			lookbackPeriod = period
+		}
+	}
+	return backtests[0].loadData(symbols, from, to, lookbackPeriod), nil
+}
+
+// runOn backtests configured combinations on loaded data in parallel.
+func (s sweep) runOn(data dataset, combinations []sweepParameters, backtests []Backtest, from time.Time, to time.Time) sweepTable {
+	parallelism := s.parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	table := make(sweepTable, len(backtests))
+	running := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range backtests {
+		wg.Add(1)
+		running <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-running }()
+			result := backtests[i].run(data, from, to, combinations[i].iterateForDays)
+			table[i] = sweepResult{parameters: combinations[i], result: result}
+		}(i)
+	}
+	wg.Wait()
+
+	return table
+}
+
+// sortBy orders results by metric, from the highest value when descending.
+func (t sweepTable) sortBy(metric string, descending bool) error {
+	if _, err := (metrics{}).value(metric); err != nil {
+		return err
+	}
+	sort.SliceStable(t, func(i, j int) bool {
+		first, _ := t[i].result.metrics.value(metric)
+		second, _ := t[j].result.metrics.value(metric)
+		if descending {
+			return first > second
+		}
+		return first < second
+	})
+	return nil
+}
+
+// writeCSV writes one line per combination with its parameters and metrics.
+func (t sweepTable) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := append([]string{"period_in_days", "criteria_weights", "portfolio_size", "iterate_for_days"}, metricNames...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range t {
+		weights := make([]string, len(row.parameters.criteriaWeights))
+		for i, weight := range row.parameters.criteriaWeights {
+			weights[i] = formatFloat(weight)
+		}
+		record := []string{
+			strconv.Itoa(row.parameters.periodInDays),
+			strings.Join(weights, ";"),
+			strconv.Itoa(row.parameters.portfolioSize),
+			strconv.Itoa(row.parameters.iterateForDays),
+		}
+		for _, metric := range metricNames {
+			value, _ := row.result.metrics.value(metric)
+			record = append(record, formatFloat(value))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// trendingCloses moves price by given fraction every day, most recent first.
+func trendingCloses(days int, dailyChange float64) []float64 {
+	closes := make([]float64, days)
+	closes[days-1] = 100
+	for i := days - 2; i >= 0; i-- {
+		closes[i] = closes[i+1] * (1 + dailyChange)
+	}
+	return closes
+}
+
+func companyGrowingBy(symbol string, netIncomeGrowth float64, dailyChange float64) companyInfo {
+	company := companyWithCloses(symbol, trendingCloses(60, dailyChange))
+	company.growth = []FinancialGrowth{{Date: "2020-06-30", NetIncomeGrowth: netIncomeGrowth}}
+	return company
+}
+
+var sweepData = dataset{companies: []companyInfo{
+	companyGrowingBy("RISE", 0.5, 0.01),
+	companyGrowingBy("FALL", 0.1, -0.01),
+}}
+
+func growthBacktest() Backtest {
+	return Backtest{
+		screener: screener{screeningStrategy: compositeStrategy{}},
+		strategy: strategy{criteria: []criterion{{criterionType: netIncomeGrowth, period: periodAnnual, weight: 1, direction: highest}}},
+		portfolio: portfolio{
+			commision: commision{},
+			capital:   10_000,
+			size:      2,
+		},
+	}
+}
+
+func TestSweep_runs_every_combination_and_sorts_by_metric(t *testing.T) {
+	// Given
+	sweep := sweep{
+		newBacktest:     growthBacktest,
+		criteriaWeights: [][]float64{{1}, {-1}},
+		portfolioSizes:  []int{1, 2},
+		iterateForDays:  []int{7},
+	}
+	combinations := sweep.combinations()
+	backtests, _ := sweep.configure(combinations)
+	from := weightingDate.AddDate(0, 0, -30)
+
+	// When
+	table := sweep.runOn(sweepData, combinations, backtests, from, weightingDate)
+	err := table.sortBy(metricTotalReturn, true)
+
+	// Then
+	if err != nil || len(table) != 4 {
+		t.Fatalf("expected 4 results, actual: %d, error: %v", len(table), err)
+	}
+	best, worst := table[0].parameters, table[3].parameters
+	if best.portfolioSize != 1 || best.criteriaWeights[0] != 1 || worst.portfolioSize != 1 || worst.criteriaWeights[0] != -1 {
+		t.Fatalf("expected concentrated portfolios of the best and the worst ranked company first and last, actual: %+v and %+v", best, worst)
+	}
+	if table[0].result.metrics.totalReturn <= 0 || table[3].result.metrics.totalReturn >= 0 {
+		t.Fatalf("expected gain of the best and loss of the worst, actual: %+v and %+v", table[0].result.metrics, table[3].result.metrics)
+	}
+}
+
+func TestSweep_table_csv_and_unknown_metric(t *testing.T) {
+	// Given
+	table := sweepTable{{parameters: sweepParameters{periodInDays: 150, criteriaWeights: []float64{0.5, 0.5}, portfolioSize: 3, iterateForDays: 30}}}
+	var csv bytes.Buffer
+
+	// When
+	err := table.writeCSV(&csv)
+	sortErr := table.sortBy("UNKNOWN", true)
+
+	// Then
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if err != nil || len(lines) != 2 || !strings.HasPrefix(lines[1], "150,0.5;0.5,3,30,") {
+		t.Fatalf("expected header and row of parameters, actual: %q, error: %v", csv.String(), err)
+	}
+	if sortErr != metricUnknown {
+		t.Fatalf("expected unknown metric error, actual: %v", sortErr)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Tax lot matching methods
+const (
+	fifo = "FIFO"
+	lifo = "LIFO"
+	// Matches the lot with the highest cost basis first, for short positions
+	// the one sold for the least, which realizes the smallest gain.
+	highestCost = "HIGHEST_COST"
+)
+
+// taxLot is an amount of shares bought, or sold short, at once. Its price
+// is cost basis per share: buy price including commision, or short sale
+// proceeds net of commision.
+type taxLot struct {
+	date   time.Time
+	shares float64
+	price  float64
+}
+
+type realizedGain struct {
+	date   time.Time
+	symbol string
+	shares float64
+	gain   float64
+}
+
+// taxRules configures how tax lots are matched and capital gains taxed.
+type taxRules struct {
+	// fifo, lifo or highestCost, fifo when empty.
+	lotMatching string
+	// Flat rate of annual capital gains tax, no tax is paid when 0.
+	rate float64
+	// Tax years following a year of net loss in which the loss can be deducted.
+	lossCarryForwardYears int
+	// Fraction of a loss which can be deducted in a single year, no limit when 0.
+	maxLossDeduction float64
+	// Whether prices of lots in other than base currency are converted at the
+	// rate of the business day before the trade instead of the trade date.
+	previousDayFxRate bool
+}
+
+// polishCapitalGainsTax is the Polish 19% flat tax ("podatek Belki") settled
+// annually, with losses deductible over the following 5 years, at most half
+// of a loss in a single year. Foreign currency amounts are converted at the
+// NBP rate of the business day before the trade.
+func polishCapitalGainsTax() taxRules {
+	return taxRules{lotMatching: fifo, rate: 0.19, lossCarryForwardYears: 5, maxLossDeduction: 0.5, previousDayFxRate: true}
+}
+
+// lotFxRate converts prices of lots of order in currency to base currency, at the
+// rate of its trade date or of the business day before when tax rules require.
+func (p *portfolio) lotFxRate(currency string, date time.Time, tradeFxRate float64) (float64, error) {
+	if !p.tax.previousDayFxRate {
+		return tradeFxRate, nil
+	}
+	return p.currency.fxRate(currency, date.AddDate(0, 0, -1))
+}
+
+type carriedLoss struct {
+	year      int
+	amount    float64
+	remaining float64
+}
+
+// settleLots matches shares reducing position with its lots, recording realized
+// gains, and opens a new lot from shares increasing it.
+func (p *portfolio) settleLots(held position, order signal, sharesChange float64, fee float64) []taxLot {
+	lots := heldLots(held)
+	costPerShare := order.price + fee/sharesChange
+
+	for len(lots) > 0 && sharesChange != 0 && math.Signbit(lots[0].shares) != math.Signbit(sharesChange) {
+		index := p.matchingLot(lots)
+		lot := &lots[index]
+		matchedShares := math.Min(math.Abs(lot.shares), math.Abs(sharesChange))
+		// Negative lot shares of short positions flip the gain
+		gain := (costPerShare - lot.price) * math.Copysign(matchedShares, lot.shares)
+		p.realizedGains = append(p.realizedGains, realizedGain{
+			date:   order.date,
+			symbol: order.company.symbol,
+			shares: matchedShares,
+			gain:   gain,
+		})
+
+		lot.shares = p.shares.round(lot.shares - math.Copysign(matchedShares, lot.shares))
+		sharesChange = p.shares.round(sharesChange - math.Copysign(matchedShares, sharesChange))
+		if lot.shares == 0 {
+			lots = append(lots[:index], lots[index+1:]...)
+		}
+	}
+
+	if sharesChange != 0 {
+		lots = append(lots, taxLot{date: order.date, shares: sharesChange, price: costPerShare})
+	}
+	return lots
+}
+
+// heldLots copies lots of position, positions opened without them
+// are treated as a single lot bought at position price.
+func heldLots(held position) []taxLot {
+	var lotShares float64
+	for _, lot := range held.lots {
+		lotShares += lot.shares
+	}
+	lots := append([]taxLot{}, held.lots...)
+	if untracked := held.amountOfShares - lotShares; math.Abs(untracked) > shareRoundingTolerance {
+		lots = append(lots, taxLot{shares: untracked, price: held.atPrice})
+	}
+	return lots
+}
+
+func (p *portfolio) matchingLot(lots []taxLot) int {
+	switch p.tax.lotMatching {
+	case lifo:
+		return len(lots) - 1
+	case highestCost:
+		matching := 0
+		for i, lot := range lots {
+			if math.Copysign(lot.price, lot.shares) > math.Copysign(lots[matching].price, lots[matching].shares) {
+				matching = i
+			}
+		}
+		return matching
+	default:
+		return 0
+	}
+}
+
+// payCapitalGainsTax settles tax of every year which ended before date.
+func (p *portfolio) payCapitalGainsTax(date time.Time) {
+	if p.taxYear == 0 {
+		p.taxYear = date.Year()
+	}
+	for ; p.taxYear < date.Year(); p.taxYear++ {
+		tax, deductions := p.capitalGainsTax(p.taxYear)
+		for i, deduction := range deductions {
+			p.carriedLosses[i].remaining -= deduction
+		}
+		if gain := p.realizedGainIn(p.taxYear); gain < 0 {
+			p.carriedLosses = append(p.carriedLosses, carriedLoss{year: p.taxYear, amount: -gain, remaining: -gain})
+		}
+		if tax > 0 {
+			log.Printf("%d: paid capital gains tax %.2f \n", p.taxYear, tax)
+		}
+		p.capital -= tax
+		p.paidTax += tax
+	}
+}
+
+// capitalGainsTax returns tax due for year and amounts deducted from each carried loss.
+func (p *portfolio) capitalGainsTax(year int) (float64, []float64) {
+	deductions := make([]float64, len(p.carriedLosses))
+	taxableGain := p.realizedGainIn(year)
+	if p.tax.rate == 0 || taxableGain <= 0 {
+		return 0, deductions
+	}
+
+	for i, loss := range p.carriedLosses {
+		if year-loss.year > p.tax.lossCarryForwardYears {
+			continue
+		}
+		deduction := loss.remaining
+		if p.tax.maxLossDeduction > 0 {
+			deduction = math.Min(deduction, loss.amount*p.tax.maxLossDeduction)
+		}
+		deductions[i] = math.Min(deduction, taxableGain)
+		taxableGain -= deductions[i]
+	}
+	return taxableGain * p.tax.rate, deductions
+}
+
+func (p *portfolio) realizedGainIn(year int) float64 {
+	var gain float64
+	for _, realized := range p.realizedGains {
+		if realized.date.Year() == year {
+			gain += realized.gain
+		}
+	}
+	return gain
+}
+
+func (p *portfolio) totalRealizedGain() float64 {
+	var gain float64
+	for _, realized := range p.realizedGains {
+		gain += realized.gain
+	}
+	return gain
+}
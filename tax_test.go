@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func portfolioWithTwoLots(lotMatching string) portfolio {
+	portfolio := portfolio{commision: commision{}, capital: 10_000, tax: taxRules{lotMatching: lotMatching}}
+	portfolio.performSignalAction(signal{date: entryDate, company: trending, price: 100, amountOfShares: 10, action: buy})
+	portfolio.performSignalAction(signal{date: entryDate.AddDate(0, 0, 1), company: trending, price: 120, amountOfShares: 10, action: buy})
+	portfolio.performSignalAction(signal{date: entryDate.AddDate(0, 0, 2), company: trending, price: 110, amountOfShares: 10, action: buy})
+	return portfolio
+}
+
+func TestTax_realized_gains_by_lot_matching(t *testing.T) {
+	for lotMatching, expectedGain := range map[string]float64{fifo: 100, lifo: 0, highestCost: -100} {
+		// Given
+		portfolio := portfolioWithTwoLots(lotMatching)
+
+		// When
+		portfolio.performSignalAction(signal{date: entryDate.AddDate(0, 0, 3), company: trending, price: 110, amountOfShares: 10, action: sell})
+
+		// Then
+		assertFloat(t, expectedGain, portfolio.totalRealizedGain())
+		if len(portfolio.positions[0].lots) != 2 {
+			t.Fatalf("expected 2 lots left after %s sale, actual lots: %+v", lotMatching, portfolio.positions[0].lots)
+		}
+	}
+}
+
+func TestTax_cost_basis_includes_commision(t *testing.T) {
+	// Given
+	portfolio := portfolio{commision: commision{fixed: 1}, capital: 10_000, shortSelling: shortSelling{size: 1}}
+	portfolio.performSignalAction(signal{date: entryDate, company: trending, price: 100, amountOfShares: 10, action: sell})
+
+	// When
+	portfolio.performSignalAction(signal{date: entryDate, company: trending, price: 90, amountOfShares: 10, action: buy})
+
+	// Then
+	assertFloat(t, 98, portfolio.totalRealizedGain())
+}
+
+func TestTax_polish_tax_with_loss_carry_forward(t *testing.T) {
+	// Given
+	portfolio := portfolio{tax: polishCapitalGainsTax(), capital: 10_000}
+	year := func(year int) time.Time { return time.Date(year, 1, 2, 0, 0, 0, 0, time.UTC) }
+	portfolio.realizedGains = []realizedGain{
+		{date: year(2019), gain: -1000},
+		{date: year(2020), gain: 1000},
+		{date: year(2021), gain: 1000},
+	}
+
+	// When
+	for date := year(2019); !date.After(year(2022)); date = date.AddDate(0, 1, 0) {
+		portfolio.payCapitalGainsTax(date)
+	}
+
+	// Then
+	// Half of the 2019 loss is deducted in 2020 and the other half in 2021
+	assertFloat(t, 2*500*0.19, portfolio.paidTax)
+	assertFloat(t, 10_000-190, portfolio.capital)
+}
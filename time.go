@@ -12,6 +12,15 @@ const (
 
 var timeParseError = errors.New("error while parsing Date. Date should have layout: " + dateLayout)
 
+// addMonths moves date by months, to the last day of the month it lands in when
+// it has fewer days, e.g. January 31 by one month to February 28, unlike AddDate.
+func addMonths(date time.Time, months int) time.Time {
+	firstOfMonth := time.Date(date.Year(), date.Month(), 1, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+	firstOfMonth = firstOfMonth.AddDate(0, months, 0)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return firstOfMonth.AddDate(0, 0, int(math.Min(float64(date.Day()), float64(lastDay)))-1)
+}
+
 func convertTimeToQuarters(from time.Time, to time.Time) (period string, limit int) {
 	duration := to.Sub(from).Hours() / 24.0
 	limit = int(math.Ceil(duration / 30.0 / 3))
@@ -23,3 +32,20 @@ func convertTimeToYears(from time.Time, to time.Time) (period string, limit int)
 	limit = int(math.Ceil(duration / 30.0 / 12))
 	return periodAnnual, limit
 }
+
+const (
+	tradingDaysInYear    = 252
+	tradingDaysInQuarter = 63
+)
+
+var tradingPeriodNotSupported = errors.New("period not supported. Supported periods are: periodAnnual, PeriodQuarter")
+
+func tradingDaysInPeriod(period string) (int, error) {
+	switch period {
+	case periodAnnual:
+		return tradingDaysInYear, nil
+	case PeriodQuarter:
+		return tradingDaysInQuarter, nil
+	}
+	return 0, tradingPeriodNotSupported
+}
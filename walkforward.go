@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// walkForward optimizes parameters of the sweep on every in-sample window
+// and applies the best of them to the out-of-sample window following it.
+// Windows roll forward by the out-of-sample window, so out-of-sample
+// windows follow each other and together cover the backtest period.
+type walkForward struct {
+	sweep sweep
+	// Calendar days of windows parameters are optimized on and applied to.
+	inSampleDays    int
+	outOfSampleDays int
+	// Metric parameters are optimized for, e.g. metricSharpe.
+	metric string
+	// Whether lower values of metric are better, e.g. of metricMaxDrawdown.
+	lowerIsBetter bool
+}
+
+type walkForwardWindow struct {
+	inSampleFrom    time.Time
+	outOfSampleFrom time.Time
+	outOfSampleTo   time.Time
+	// Best parameters of in-sample window and its metrics.
+	parameters  sweepParameters
+	inSample    metrics
+	outOfSample backtestResult
+}
+
+// walkForwardResult is out-of-sample performance of every window, with
+// equity curves of out-of-sample windows chained into one.
+type walkForwardResult struct {
+	windows     []walkForwardWindow
+	equityCurve []equityPoint
+	metrics     metrics
+}
+
+var windowsMissing = errors.New("walk-forward requires in-sample and out-of-sample windows within backtest period")
+
+// windows splits period into windows of in-sample days followed by
+// out-of-sample days, the last out-of-sample window may be shorter.
+func (w walkForward) windows(from time.Time, to time.Time) []walkForwardWindow {
+	windows := make([]walkForwardWindow, 0)
+	if w.inSampleDays <= 0 || w.outOfSampleDays <= 0 {
+		return windows
+	}
+	for start := from; ; start = start.AddDate(0, 0, w.outOfSampleDays) {
+		outOfSampleFrom := start.AddDate(0, 0, w.inSampleDays)
+		if !outOfSampleFrom.Before(to) {
+			return windows
+		}
+		outOfSampleTo := outOfSampleFrom.AddDate(0, 0, w.outOfSampleDays)
+		if outOfSampleTo.After(to) {
+			outOfSampleTo = to
+		}
+		windows = append(windows, walkForwardWindow{
+			inSampleFrom:    start,
+			outOfSampleFrom: outOfSampleFrom,
+			outOfSampleTo:   outOfSampleTo,
+		})
+	}
+}
+
+// run loads data for the whole period once and walks forward over it.
+func (w walkForward) run(symbols []string, from time.Time, to time.Time) (walkForwardResult, error) {
+	data, err := w.sweep.loadData(symbols, from, to, w.sweep.combinations())
+	if err != nil {
+		return walkForwardResult{}, err
+	}
+	return w.runOn(data, from, to)
+}
+
+func (w walkForward) runOn(data dataset, from time.Time, to time.Time) (walkForwardResult, error) {
+	windows := w.windows(from, to)
+	if len(windows) == 0 {
+		return walkForwardResult{}, windowsMissing
+	}
+	combinations := w.sweep.combinations()
+
+	var equityCurve []equityPoint
+	var turnover float64
+	for i, window := range windows {
+		backtests, err := w.sweep.configure(combinations)
+		if err != nil {
+			return walkForwardResult{}, err
+		}
+		table := w.sweep.runOn(data, combinations, backtests, window.inSampleFrom, window.outOfSampleFrom)
+		if err := table.sortBy(w.metric, !w.lowerIsBetter); err != nil {
+			return walkForwardResult{}, err
+		}
+		window.parameters = table[0].parameters
+		window.inSample = table[0].result.metrics
+
+		best, err := w.sweep.configure([]sweepParameters{window.parameters})
+		if err != nil {
+			return walkForwardResult{}, err
+		}
+		startValue := best[0].portfolio.capital
+		window.outOfSample = best[0].run(data, window.outOfSampleFrom, window.outOfSampleTo, window.parameters.iterateForDays)
+		windows[i] = window
+
+		equityCurve = chainEquityCurve(equityCurve, equityPoint{window.outOfSampleFrom, startValue}, window.outOfSample)
+		turnover += window.outOfSample.turnover / float64(len(windows))
+	}
+
+	return walkForwardResult{
+		windows:     windows,
+		equityCurve: equityCurve,
+		metrics:     calculateMetrics(equityCurve, nil, turnover),
+	}, nil
+}
+
+// chainEquityCurve appends equity curve of a window starting from start,
+// scaled so that it continues from the last value of the chained curve.
+// Deposits and withdrawals are taken out, so only returns are chained.
+func chainEquityCurve(chained []equityPoint, start equityPoint, result backtestResult) []equityPoint {
+	if len(chained) == 0 {
+		chained = append(chained, start)
+	}
+	value := chained[len(chained)-1].value
+	windowCurve := append([]equityPoint{start}, result.equityCurve...)
+	returns := dailyEquityReturns(windowCurve, result.cashFlows)
+	for i, dailyReturn := range returns {
+		value *= 1 + dailyReturn
+		chained = append(chained, equityPoint{windowCurve[i+1].date, value})
+	}
+	return chained
+}
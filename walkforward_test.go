@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWalkForward_rolling_windows(t *testing.T) {
+	// Given
+	walkForward := walkForward{inSampleDays: 14, outOfSampleDays: 7}
+	from := weightingDate.AddDate(0, 0, -30)
+
+	// When
+	windows := walkForward.windows(from, weightingDate)
+
+	// Then
+	if len(windows) != 3 {
+		t.Fatalf("expected 3 windows, actual: %+v", windows)
+	}
+	last := windows[2]
+	if !last.inSampleFrom.Equal(from.AddDate(0, 0, 14)) || !last.outOfSampleFrom.Equal(from.AddDate(0, 0, 28)) || !last.outOfSampleTo.Equal(weightingDate) {
+		t.Fatalf("expected the last window to be cut at the end of period, actual: %+v", last)
+	}
+}
+
+func TestWalkForward_applies_in_sample_best_parameters_out_of_sample(t *testing.T) {
+	// Given
+	walkForward := walkForward{
+		sweep: sweep{
+			newBacktest:     growthBacktest,
+			criteriaWeights: [][]float64{{-1}, {1}},
+			portfolioSizes:  []int{1},
+			iterateForDays:  []int{7},
+		},
+		inSampleDays:    14,
+		outOfSampleDays: 7,
+		metric:          metricTotalReturn,
+	}
+	from := weightingDate.AddDate(0, 0, -40)
+
+	// When
+	result, err := walkForward.runOn(sweepData, from, weightingDate)
+
+	// Then
+	if err != nil || len(result.windows) != 4 {
+		t.Fatalf("expected 4 windows, actual: %+v, error: %v", result.windows, err)
+	}
+	for _, window := range result.windows {
+		if window.parameters.criteriaWeights[0] != 1 {
+			t.Fatalf("expected rising company to be chosen in sample, actual parameters: %+v", window.parameters)
+		}
+	}
+	curve := result.equityCurve
+	if !curve[0].date.Equal(result.windows[0].outOfSampleFrom) || curve[0].value != 10_000 || curve[len(curve)-1].value <= 10_000 {
+		t.Fatalf("expected stitched curve to start with capital and grow, actual: %+v", curve)
+	}
+	if result.metrics.totalReturn <= 0 {
+		t.Fatalf("expected out of sample gain, actual metrics: %+v", result.metrics)
+	}
+}
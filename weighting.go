@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// Weighting schemes
+const (
+	equalWeight           = "EQUAL"
+	scoreProportional     = "SCORE_PROPORTIONAL"
+	inverseVolatility     = "INVERSE_VOLATILITY"
+	equalRiskContribution = "EQUAL_RISK_CONTRIBUTION"
+	marketCapWeighted     = "MARKET_CAP"
+	minimumVariance       = "MINIMUM_VARIANCE"
+	maxSharpe             = "MAX_SHARPE"
+)
+
+// Iterations of equal risk contribution solver, which converges
+// well before that for any realistic portfolio size.
+const ercIterations = 500
+
+// weighting decides which part of the invested capital goes to each company.
+// Empty scheme means equal weight.
+type weighting struct {
+	scheme string
+	// Trading days of returns used to estimate volatility and covariance,
+	// tradingDaysInQuarter when 0.
+	lookbackDays int
+	// Upper bound of a single weight, none when 0. Capital above it is
+	// spread over the other companies or, if all are capped, kept in cash.
+	maxWeight float64
+	// Upper bound of sum of absolute weight changes against currently
+	// held weights per rebalance, none when 0. Held companies no longer
+	// selected are sold in full and use the budget first, so it is exceeded
+	// only when they alone exceed it.
+	maxTurnover float64
+	// Annual rate Sharpe ratio is measured against by maxSharpe.
+	riskFreeRate float64
+}
+
+var unsupportedWeighting = errors.New("unknown weighting scheme")
+
+// calculateWeights returns weights of companies summing to at most 1.
+// Current weights are the ones companies are held with now, absent when not held.
+func (w weighting) calculateWeights(companies []companyInfo, scores map[string]float64, currentWeights map[string]float64, date time.Time) ([]float64, error) {
+	if len(companies) == 0 {
+		return make([]float64, 0), nil
+	}
+
+	var weights []float64
+	var err error
+	switch w.scheme {
+	case "", equalWeight:
+		weights = equalWeights(len(companies))
+	case scoreProportional:
+		weights = scoreProportionalWeights(companies, scores)
+	case inverseVolatility:
+		weights, err = inverseVolatilityWeights(companies, date, w.lookback())
+	case equalRiskContribution:
+		weights, err = equalRiskContributionWeights(companies, date, w.lookback())
+	case marketCapWeighted:
+		weights, err = marketCapWeights(companies, date)
+	case minimumVariance:
+		weights, err = minimumVarianceWeights(companies, date, w.lookback(), w.maxWeight)
+	case maxSharpe:
+		weights, err = maxSharpeWeights(companies, date, w.lookback(), w.maxWeight, w.riskFreeRate)
+	default:
+		log.Println(unsupportedWeighting, " "+w.scheme)
+		return nil, unsupportedWeighting
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	weights = capWeights(weights, w.maxWeight)
+	if w.maxTurnover > 0 {
+		current := make([]float64, len(companies))
+		selected := make(map[string]bool, len(companies))
+		for i, company := range companies {
+			current[i] = currentWeights[company.symbol]
+			selected[company.symbol] = true
+		}
+		var exitTurnover float64
+		for symbol, weight := range currentWeights {
+			if !selected[symbol] && weight > 0 {
+				exitTurnover += weight
+			}
+		}
+		weights = limitTurnover(weights, current, math.Max(0, w.maxTurnover-exitTurnover))
+		// Current weights are relative to the value weights are applied to, so
+		// holdings worth more than it leave limited weights summing above 1.
+		var sum float64
+		for _, weight := range weights {
+			sum += weight
+		}
+		if sum > 1 {
+			weights = normalizeWeights(weights)
+		}
+	}
+
+	return weights, nil
+}
+
+func (w weighting) lookback() int {
+	if w.lookbackDays == 0 {
+		return tradingDaysInQuarter
+	}
+	return w.lookbackDays
+}
+
+// lookbackInDays returns the number of trading days of price history
+// position sizing needs before the first backtest date.
+func (p *portfolio) lookbackInDays() int {
+	usesPriceHistory := p.weighting.scheme != "" && p.weighting.scheme != equalWeight &&
+		p.weighting.scheme != scoreProportional && p.weighting.scheme != marketCapWeighted
+	if usesPriceHistory || p.shortSelling.neutrality == betaNeutral {
+		return p.weighting.lookback() + 1
+	}
+	return 0
+}
+
+func equalWeights(amount int) []float64 {
+	weights := make([]float64, amount)
+	for i := range weights {
+		weights[i] = 1 / float64(amount)
+	}
+	return weights
+}
+
+// scoreProportionalWeights weights companies by their final strategy result.
+// Negative results get no weight.
+func scoreProportionalWeights(companies []companyInfo, scores map[string]float64) []float64 {
+	weights := make([]float64, len(companies))
+	for i, company := range companies {
+		weights[i] = math.Max(scores[company.symbol], 0)
+	}
+	return normalizeWeights(weights)
+}
+
+func inverseVolatilityWeights(companies []companyInfo, date time.Time, lookbackDays int) ([]float64, error) {
+	weights := make([]float64, len(companies))
+	for i, company := range companies {
+		closes, err := getClosePrices(company.historicalPrice.Historical, date, lookbackDays)
+		if err != nil {
+			return nil, err
+		}
+		volatility := StdDev(dailyReturns(closes)...)
+		if volatility > 0 {
+			weights[i] = 1 / volatility
+		}
+	}
+	return normalizeWeights(weights), nil
+}
+
+// equalRiskContributionWeights finds weights every company contributes the same
+// amount of portfolio variance with, using cyclical coordinate descent.
+func equalRiskContributionWeights(companies []companyInfo, date time.Time, lookbackDays int) ([]float64, error) {
+	covariance, err := covarianceMatrix(companies, date, lookbackDays)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(companies)
+	riskBudget := 1 / float64(n)
+	weights := equalWeights(n)
+	for iteration := 0; iteration < ercIterations; iteration++ {
+		for i := 0; i < n; i++ {
+			if covariance[i][i] <= 0 {
+				continue
+			}
+			var crossTerm float64
+			for j := 0; j < n; j++ {
+				if j != i {
+					crossTerm += covariance[i][j] * weights[j]
+				}
+			}
+			weights[i] = (-crossTerm + math.Sqrt(crossTerm*crossTerm+4*covariance[i][i]*riskBudget)) / (2 * covariance[i][i])
+		}
+	}
+
+	return normalizeWeights(weights), nil
+}
+
+func marketCapWeights(companies []companyInfo, date time.Time) ([]float64, error) {
+	weights := make([]float64, len(companies))
+	for i, company := range companies {
+		marketCap, err := getMarketCap(company, date)
+		if err != nil {
+			return nil, err
+		}
+		weights[i] = marketCap
+	}
+	return normalizeWeights(weights), nil
+}
+
+// covarianceMatrix estimates covariance of daily returns of companies
+// over given number of trading days preceding date.
+func covarianceMatrix(companies []companyInfo, date time.Time, lookbackDays int) ([][]float64, error) {
+	returns := make([][]float64, len(companies))
+	for i, company := range companies {
+		closes, err := getClosePrices(company.historicalPrice.Historical, date, lookbackDays)
+		if err != nil {
+			return nil, err
+		}
+		returns[i] = dailyReturns(closes)
+	}
+
+	covariance := make([][]float64, len(companies))
+	for i := range covariance {
+		covariance[i] = make([]float64, len(companies))
+		for j := range covariance[i] {
+			covariance[i][j] = Covariance(returns[i], returns[j])
+		}
+	}
+
+	return covariance, nil
+}
+
+// normalizeWeights scales weights to sum to 1, falling back
+// to equal weights when there is nothing to scale.
+func normalizeWeights(weights []float64) []float64 {
+	var sum float64
+	for _, weight := range weights {
+		sum += weight
+	}
+	if sum <= 0 {
+		return equalWeights(len(weights))
+	}
+
+	normalized := make([]float64, len(weights))
+	for i, weight := range weights {
+		normalized[i] = weight / sum
+	}
+	return normalized
+}
+
+// capWeights limits weights to maxWeight, redistributing the excess
+// proportionally among weights still below it.
+func capWeights(weights []float64, maxWeight float64) []float64 {
+	if maxWeight <= 0 {
+		return weights
+	}
+
+	capped := make([]bool, len(weights))
+	for {
+		var excess, uncappedSum float64
+		for i, weight := range weights {
+			if weight > maxWeight {
+				excess += weight - maxWeight
+				weights[i] = maxWeight
+				capped[i] = true
+			} else if !capped[i] {
+				uncappedSum += weight
+			}
+		}
+		if excess <= 1e-12 || uncappedSum == 0 {
+			return weights
+		}
+		for i := range weights {
+			if !capped[i] {
+				weights[i] += excess * weights[i] / uncappedSum
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var weightingDate, _ = time.Parse(dateLayout, "2021-01-20")
+
+// companyWithCloses builds company with daily close prices ending
+// on weightingDate, most recent first.
+func companyWithCloses(symbol string, closes []float64) companyInfo {
+	historical := make([]Price, len(closes))
+	day := weightingDate
+	for i, close := range closes {
+		historical[i] = Price{Date: day.Format(dateLayout), Close: close, Open: close, High: close, Low: close}
+		day = day.AddDate(0, 0, -1)
+	}
+	return companyInfo{symbol: symbol, historicalPrice: HistoricalPrice{Symbol: symbol, Historical: historical}}
+}
+
+// alternatingCloses swings price by given fraction every day,
+// so its volatility is proportional to the swing.
+func alternatingCloses(days int, swing float64) []float64 {
+	closes := make([]float64, days)
+	for i := range closes {
+		closes[i] = 100
+		if i%2 == 1 {
+			closes[i] = 100 * (1 + swing)
+		}
+	}
+	return closes
+}
+
+func TestWeights_score_proportional_ignores_negative_scores(t *testing.T) {
+	// Given
+	companies := []companyInfo{{symbol: "A"}, {symbol: "B"}, {symbol: "C"}}
+	scores := map[string]float64{"A": 3, "B": 1, "C": -1}
+
+	// When
+	weights, err := weighting{scheme: scoreProportional}.calculateWeights(companies, scores, nil, weightingDate)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{0.75, 0.25, 0}, weights)
+}
+
+func TestWeights_capped_redistribute_excess(t *testing.T) {
+	// When
+	weights := capWeights([]float64{0.7, 0.2, 0.1}, 0.4)
+
+	// Then
+	assertValues(t, []float64{0.4, 0.4, 0.2}, weights)
+}
+
+func TestWeights_inverse_volatility_and_equal_risk_contribution(t *testing.T) {
+	// Given
+	calm := companyWithCloses("CALM", alternatingCloses(11, 0.01))
+	wild := companyWithCloses("WILD", alternatingCloses(11, 0.03))
+	companies := []companyInfo{calm, wild}
+
+	// When
+	inverseVolatilityResult, inverseVolatilityErr := weighting{scheme: inverseVolatility, lookbackDays: 10}.calculateWeights(companies, nil, nil, weightingDate)
+	ercResult, ercErr := weighting{scheme: equalRiskContribution, lookbackDays: 10}.calculateWeights(companies, nil, nil, weightingDate)
+
+	// Then
+	if inverseVolatilityErr != nil || ercErr != nil {
+		t.Fatalf("unexpected errors: %s, %s", inverseVolatilityErr, ercErr)
+	}
+	if inverseVolatilityResult[0] <= inverseVolatilityResult[1] {
+		t.Fatalf("expected calm company to weigh more, actual weights: %v", inverseVolatilityResult)
+	}
+	covariance, _ := covarianceMatrix(companies, weightingDate, 10)
+	riskContribution := func(i int) float64 {
+		var marginal float64
+		for j := range ercResult {
+			marginal += covariance[i][j] * ercResult[j]
+		}
+		return ercResult[i] * marginal
+	}
+	if math.Abs(riskContribution(0)-riskContribution(1)) > 1e-9 {
+		t.Fatalf("expected equal risk contributions, actual: %e, %e", riskContribution(0), riskContribution(1))
+	}
+}
+
+func TestWeights_market_cap(t *testing.T) {
+	// Given
+	companies := []companyInfo{largeLowDebt, smallLowDebt}
+
+	// When
+	weights, err := weighting{scheme: marketCapWeighted}.calculateWeights(companies, nil, nil, weightingDate)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertValues(t, []float64{50.0 / 52, 2.0 / 52}, weights)
+}
+
+func TestWeights_turnover_limit_includes_exits(t *testing.T) {
+	// Given
+	companies := []companyInfo{{symbol: "A"}, {symbol: "B"}, {symbol: "C"}}
+	currentWeights := map[string]float64{"A": 0.5, "B": 0.3, "D": 0.2}
+
+	// When
+	weights, err := weighting{maxTurnover: 0.5}.calculateWeights(companies, nil, currentWeights, weightingDate)
+
+	// Then
+	// Selling all of D takes 0.2 of the budget, leaving 0.3 for A, B and C
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	turnover := 0.2
+	for i, company := range companies {
+		turnover += math.Abs(weights[i] - currentWeights[company.symbol])
+	}
+	assertFloat(t, 0.5, turnover)
+}
+
+func TestWeights_turnover_limit_keeps_weights_summing_to_one(t *testing.T) {
+	// Given
+	companies := []companyInfo{{symbol: "A"}, {symbol: "B"}}
+	currentWeights := map[string]float64{"A": 0.9, "B": 0.6}
+
+	// When
+	weights, err := weighting{maxTurnover: 0.2}.calculateWeights(companies, nil, currentWeights, weightingDate)
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if weights[0] <= weights[1] {
+		t.Fatalf("expected weights to move only part of the way, actual weights: %v", weights)
+	}
+	assertFloat(t, 1, weights[0]+weights[1])
+}